@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+)
+
+// ErrAlreadyMigrated happens when baselining a database that already has
+// migrations recorded
+var ErrAlreadyMigrated = errors.New("database already has migrations recorded")
+
+// Baseline records the migrations up to and including version as applied
+// without running them. This is useful when adopting migrate on an existing
+// database. Baseline refuses to run if migrations have already been recorded,
+// unless force is true, in which case the recorded versions are replaced.
+func Baseline(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, version uint, force bool, options ...Option) error {
+	log = logger(log)
+	config := newConfig(log, options)
+	set, err := Load(fsys)
+	if err != nil {
+		return err
+	} else if len(set.Pairs) == 0 {
+		return noMigrations(set.files)
+	}
+	if set.Find(version) == nil {
+		return fmt.Errorf("unable to baseline to %s, no migration has that version", pad(version))
+	}
+	if err := ensureTableExists(db, tableName); err != nil {
+		return err
+	}
+	remote, err := getRemoteVersion(db, tableName, config.namespace)
+	if err != nil {
+		return err
+	} else if remote > 0 && !force {
+		return fmt.Errorf("%w (at version %s), use force to overwrite", ErrAlreadyMigrated, pad(remote))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM "+quote(tableName)+" WHERE namespace=$1", config.namespace); err != nil {
+		return err
+	}
+	for _, migration := range set.Ups() {
+		if migration.Version > version {
+			break
+		}
+		if err := insertVersion(tx, tableName, config.namespace, migration.Version); err != nil {
+			return err
+		}
+		if err := insertHistory(tx, tableName, config.namespace, migration, "baseline"); err != nil {
+			return err
+		}
+		log.Info("baselined: " + migration.Name)
+	}
+
+	return tx.Commit()
+}
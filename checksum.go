@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+)
+
+// checksum of the migration code
+func checksum(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Checksum of the migration's code
+func (m *Migration) Checksum() string {
+	return checksum(m.Code)
+}
+
+// checksumColumns are the columns of the tables that track migrations by
+// name and checksum. Each namespace tracks its own names.
+const checksumColumns = "namespace text not null default '', name text not null, checksum text not null, applied_at timestamp not null default current_timestamp, primary key (namespace, name)"
+
+// ensureChecksumTableExists creates a table that tracks migrations by name
+// and checksum, rather than by version
+func ensureChecksumTableExists(db *sql.DB, table string) error {
+	if err := ensureSchemaExists(db, table); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + quote(table) + " (" + checksumColumns + ");"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getChecksums returns the checksums of the migrations applied in the
+// namespace by name
+func getChecksums(db *sql.DB, table, namespace string) (map[string]string, error) {
+	rows, err := db.Query("SELECT name, checksum FROM "+quote(table)+" WHERE namespace=$1", namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	checksums := map[string]string{}
+	for rows.Next() {
+		var name, sum string
+		if err := rows.Scan(&name, &sum); err != nil {
+			return nil, err
+		}
+		checksums[name] = sum
+	}
+	return checksums, rows.Err()
+}
+
+// upsertChecksum records the migration as applied in the namespace
+func upsertChecksum(tx *sql.Tx, table, namespace string, migration *Migration) error {
+	if _, err := tx.Exec("DELETE FROM "+quote(table)+" WHERE namespace=$1 AND name=$2", namespace, migration.Name); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO "+quote(table)+" (namespace, name, checksum) VALUES ($1, $2, $3)", namespace, migration.Name, migration.Checksum()); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/matthewmueller/migrate/internal/schema"
+	"github.com/matthewmueller/virt"
+)
+
+// ErrNoChanges happens when there's no difference between two schemas
+var ErrNoChanges = errors.New("no schema changes")
+
+// Diff compares the schema of the current database with the desired database
+// and returns the up and down migrations to get from one to the other.
+// Destructive statements are flagged with a comment.
+func Diff(current, desired *sql.DB, tableName string) (up, down string, err error) {
+	from, err := schema.Inspect(current, trackingTables(tableName)...)
+	if err != nil {
+		return "", "", err
+	}
+	to, err := schema.Inspect(desired, trackingTables(tableName)...)
+	if err != nil {
+		return "", "", err
+	}
+	up, down = schema.Diff(from, to), schema.Diff(to, from)
+	if up == "" && down == "" {
+		return "", "", ErrNoChanges
+	}
+	return up, down, nil
+}
+
+// NewDiff creates a new migration in fsys that moves the current database's
+// schema to the desired database's schema. It returns the paths of the up and
+// down files.
+func NewDiff(log *slog.Logger, fsys virt.FS, name string, current, desired *sql.DB, tableName string) (paths []string, err error) {
+	log = logger(log)
+	up, down, err := Diff(current, desired, tableName)
+	if err != nil {
+		return nil, err
+	}
+	return create(log, fsys, name, up+"\n", down+"\n")
+}
@@ -0,0 +1,156 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ErrDirty happens when a previous migration started but didn't finish. Repair
+// the database by hand, then use Force to set the version.
+var ErrDirty = errors.New("database is dirty")
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// transact runs fn in a transaction while versions are marked dirty. The
+// markers are cleared within the transaction and again if the transaction
+// fails, so they're only left behind when the process dies partway through.
+func transact(db *sql.DB, tableName, namespace string, versions []uint, fn func(tx *sql.Tx) error) error {
+	if err := markDirty(db, tableName, namespace, versions); err != nil {
+		return err
+	}
+	err := transaction(db, func(tx *sql.Tx) error {
+		if err := clearDirty(tx, tableName, namespace, versions); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+	if err != nil {
+		return cleanup(err, db, tableName, namespace, versions)
+	}
+	return nil
+}
+
+// transaction runs fn in a transaction, committing if it succeeds. The
+// transaction is rolled back before returning an error.
+func transaction(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// versions of the migrations
+func versions(migrations []*Migration) []uint {
+	versions := make([]uint, len(migrations))
+	for i, migration := range migrations {
+		versions[i] = migration.Version
+	}
+	return versions
+}
+
+// cleanup clears the dirty markers after a failed transaction
+func cleanup(err error, db *sql.DB, tableName, namespace string, versions []uint) error {
+	if cerr := clearDirty(db, tableName, namespace, versions); cerr != nil {
+		return errors.Join(err, cerr)
+	}
+	return err
+}
+
+// markDirty records that versions are about to be migrated. Each marker is a
+// dirty row of its own under the negated version, so it's never mistaken for
+// an applied version.
+func markDirty(db execer, tableName, namespace string, versions []uint) error {
+	for _, version := range versions {
+		if _, err := db.Exec("INSERT INTO "+quote(tableName)+" (namespace, version, dirty) VALUES ($1, $2, $3)", namespace, -int64(version), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearDirty undoes markDirty
+func clearDirty(db execer, tableName, namespace string, versions []uint) error {
+	for _, version := range versions {
+		if _, err := db.Exec("DELETE FROM "+quote(tableName)+" WHERE namespace=$1 AND version=$2 AND dirty=$3", namespace, -int64(version), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDirty returns an error naming the versions of a run that didn't finish
+func checkDirty(db *sql.DB, tableName, namespace string) error {
+	rows, err := db.Query("SELECT version FROM "+quote(tableName)+" WHERE namespace=$1 AND dirty=$2 ORDER BY version DESC", namespace, true)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var versions []string
+	for rows.Next() {
+		var version int64
+		if err := rows.Scan(&version); err != nil {
+			return err
+		}
+		versions = append(versions, pad(uint(-version)))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	} else if len(versions) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w, %s didn't finish migrating, repair it by hand and then run force", ErrDirty, strings.Join(versions, ", "))
+}
+
+// Force sets the recorded version after a dirty database has been repaired by
+// hand. Versions above version are removed, the dirty marker is cleared and
+// the change is recorded in the history table.
+func Force(log *slog.Logger, db *sql.DB, tableName string, version uint, options ...Option) error {
+	log = logger(log)
+	config := newConfig(log, options)
+	if err := ensureTableExists(db, tableName); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM "+quote(tableName)+" WHERE namespace=$1 AND version > $2", config.namespace, version); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM "+quote(tableName)+" WHERE namespace=$1 AND dirty=$2", config.namespace, true); err != nil {
+		return err
+	}
+	if version > 0 {
+		var count int
+		if err := tx.QueryRow("SELECT count(*) FROM "+quote(tableName)+" WHERE namespace=$1 AND version=$2 AND dirty=$3", config.namespace, version, false).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := insertVersion(tx, tableName, config.namespace, version); err != nil {
+				return err
+			}
+		}
+	}
+	if err := insertHistory(tx, tableName, config.namespace, &Migration{Version: version}, "force"); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Info("forced version " + pad(version))
+	return nil
+}
@@ -0,0 +1,30 @@
+package migrate
+
+import (
+	"database/sql"
+
+	"github.com/matthewmueller/migrate/internal/schema"
+)
+
+// schemaFile is the file that the CLI dumps the schema to within the
+// migrations directory
+const schemaFile = "schema.sql"
+
+// Dump introspects the database and returns a deterministic schema.sql,
+// including the current migration version. The migrate table itself is left
+// out of the dump.
+func Dump(db *sql.DB, tableName string, options ...Option) (string, error) {
+	config := newConfig(nil, options)
+	if err := ensureTableExists(db, tableName); err != nil {
+		return "", err
+	}
+	remote, err := getRemoteVersion(db, tableName, config.namespace)
+	if err != nil {
+		return "", err
+	}
+	schema, err := schema.Inspect(db, trackingTables(tableName)...)
+	if err != nil {
+		return "", err
+	}
+	return "-- Generated by migrate. DO NOT EDIT.\n-- Version: " + pad(remote) + "\n\n" + schema.String() + "\n", nil
+}
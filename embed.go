@@ -0,0 +1,112 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Embed returns the migrations in dir within fsys. It's meant for apps that
+// ship their migrations inside the binary and migrate when they start up:
+//
+//	//go:embed migrate/*.sql
+//	var embedded embed.FS
+//
+//	func main() {
+//		// ...
+//		if err := migrate.Up(log, db, migrate.Embed(embedded, "migrate"), "migrate"); err != nil {
+//			log.Error("unable to migrate", "error", err)
+//			os.Exit(1)
+//		}
+//	}
+//
+// Passing embedded directly also works, since a single nested migrations
+// directory is detected, but Embed makes the location explicit.
+func Embed(fsys fs.FS, dir string) fs.FS {
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return errFS{err}
+	}
+	return sub
+}
+
+// errFS fails to open anything
+type errFS struct{ err error }
+
+func (e errFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: e.err}
+}
+
+// unnest finds the migrations when they're in a subdirectory of fsys rather
+// than at the root, like an embed.FS of migrate/*.sql that was passed without
+// fs.Sub. The files are re-rooted at that directory. Multiple directories of
+// migrations are ambiguous.
+func unnest(files map[string]string) (map[string]string, error) {
+	dirs := map[string]bool{}
+	for name := range files {
+		dir, base := path.Split(name)
+		if !isMigrationFile(base) {
+			continue
+		} else if dir == "" {
+			return files, nil
+		}
+		dir = strings.TrimSuffix(dir, "/")
+		if path.Base(dir) == archiveDir {
+			continue
+		}
+		dirs[dir] = true
+	}
+	if len(dirs) == 0 {
+		return files, nil
+	} else if len(dirs) > 1 {
+		names := make([]string, 0, len(dirs))
+		for dir := range dirs {
+			names = append(names, dir)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("found migrations in more than one directory (%s), use migrate.Embed or fs.Sub to pick one", strings.Join(names, ", "))
+	}
+	var prefix string
+	for dir := range dirs {
+		prefix = dir + "/"
+	}
+	unnested := make(map[string]string)
+	for name, code := range files {
+		if rest, ok := strings.CutPrefix(name, prefix); ok {
+			unnested[rest] = code
+		}
+	}
+	return unnested, nil
+}
+
+// isMigrationFile returns true if name is a versioned or repeatable migration
+func isMigrationFile(name string) bool {
+	if reRepeatable.MatchString(name) {
+		return true
+	} else if !reFile.MatchString(name) {
+		return false
+	}
+	_, err := getDirection(name)
+	return err == nil
+}
+
+// noMigrations explains why none of the files are migrations. Files that
+// don't follow the naming scheme are called out, rather than silently
+// skipped. The schema dump isn't a misnamed migration.
+func noMigrations(files map[string]string) error {
+	var unmatched []string
+	for name := range files {
+		dir, _ := path.Split(name)
+		if isMigrationFile(name) || path.Ext(name) != ".sql" || dir == templatesDir+"/" || dir == archiveDir+"/" || dir == "seeds/" || name == schemaFile {
+			continue
+		}
+		unmatched = append(unmatched, name)
+	}
+	if len(unmatched) == 0 {
+		return ErrNoMigrations
+	}
+	sort.Strings(unmatched)
+	return fmt.Errorf("%w matching 001_name.up.sql or 001_name.down.sql, found %s", ErrNoMigrations, strings.Join(unmatched, ", "))
+}
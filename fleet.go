@@ -0,0 +1,45 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+
+	database "github.com/matthewmueller/migrate/internal/db"
+)
+
+// UpGlob migrates each SQLite database file matching pattern (e.g.
+// data/*.db) up to the latest migration. Each file is opened with its own
+// connection, so the sqlite3 driver must be registered by the caller. Up to
+// workers files are migrated at once. Unless keepGoing is true, files that
+// haven't started yet are skipped after the first failure.
+func UpGlob(log *slog.Logger, pattern string, fsys fs.FS, tableName string, workers int, keepGoing bool, options ...Option) ([]*Result, error) {
+	log = logger(log)
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	} else if len(paths) == 0 {
+		return nil, fmt.Errorf("no databases match %q", pattern)
+	}
+	targets := make([]target, len(paths))
+	for i, path := range paths {
+		targets[i] = target{path, func() (uint, error) {
+			return upFile(log.With("db", path), path, fsys, tableName, options)
+		}}
+	}
+	return migrateAll(targets, workers, keepGoing), nil
+}
+
+// upFile migrates a single SQLite database file
+func upFile(log *slog.Logger, path string, fsys fs.FS, tableName string, options []Option) (version uint, err error) {
+	db, err := database.Dial("sqlite:" + path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err := Up(log, db, fsys, tableName, options...); err != nil {
+		return 0, err
+	}
+	return getRemoteVersion(db, tableName, newConfig(log, options).namespace)
+}
@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// Run is a batch of migrations that are applied within a single transaction
+type Run struct {
+	Migrations []*Migration
+}
+
+// Hooks are called around each run and each migration. Errors returned from
+// BeforeRun, BeforeMigration and AfterMigration roll back the run before it's
+// committed. AfterRun is called once the run has been committed or rolled
+// back.
+type Hooks interface {
+	BeforeRun(run *Run) error
+	BeforeMigration(migration *Migration) error
+	AfterMigration(migration *Migration, duration time.Duration, err error) error
+	AfterRun(run *Run, err error) error
+}
+
+// NopHooks does nothing. Embed it to only implement the hooks you need.
+type NopHooks struct{}
+
+var _ Hooks = NopHooks{}
+
+func (NopHooks) BeforeRun(*Run) error                                  { return nil }
+func (NopHooks) BeforeMigration(*Migration) error                      { return nil }
+func (NopHooks) AfterMigration(*Migration, time.Duration, error) error { return nil }
+func (NopHooks) AfterRun(*Run, error) error                            { return nil }
+
+// run calls fn between the BeforeRun and AfterRun hooks, logging the executed
+// migrations once they've been committed
+func (c *config) run(migrations []*Migration, fn func() error) error {
+	run := &Run{Migrations: migrations}
+	if err := c.hooks.BeforeRun(run); err != nil {
+		return err
+	}
+	c.executed = c.executed[:0]
+	start := time.Now()
+	err := fn()
+	if err == nil {
+		c.logRun(time.Since(start))
+	}
+	if herr := c.hooks.AfterRun(run, err); herr != nil && err == nil {
+		return herr
+	}
+	return err
+}
+
+// exec executes the migration between the BeforeMigration and AfterMigration
+// hooks
+func (c *config) exec(tx *sql.Tx, migration *Migration) error {
+	if err := c.hooks.BeforeMigration(migration); err != nil {
+		return err
+	}
+	c.log.Debug("executing migration", "name", migration.Name, "sql", migration.Code)
+	if err := c.setSearchPath(tx); err != nil {
+		return err
+	}
+	start := time.Now()
+	_, err := tx.Exec(migration.Code)
+	duration := time.Since(start)
+	if err == nil {
+		err = c.resetSearchPath(tx)
+	}
+	if err != nil {
+		err = format(migration, err)
+	}
+	if herr := c.hooks.AfterMigration(migration, duration, err); herr != nil && err == nil {
+		return herr
+	} else if err != nil {
+		return err
+	}
+	c.executed = append(c.executed, &executed{migration, duration, countStatements(migration.Code)})
+	return nil
+}
+
+// logRun logs each executed migration, followed by a summary
+func (c *config) logRun(duration time.Duration) {
+	statements := 0
+	for _, executed := range c.executed {
+		migration := executed.migration
+		statements += executed.statements
+		c.log.Info(migration.Name,
+			slog.Uint64("version", uint64(migration.Version)),
+			slog.String("name", migration.Name),
+			slog.String("direction", string(migration.Dir)),
+			slog.Duration("duration", executed.duration),
+			slog.Int("statements", executed.statements),
+		)
+	}
+	c.log.Info("migrations committed",
+		slog.Int("migrations", len(c.executed)),
+		slog.Int("statements", statements),
+		slog.Duration("duration", duration),
+	)
+}
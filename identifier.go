@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// reIdentifier matches a plain SQL identifier
+var reIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateTable checks that the table name is an identifier, optionally
+// qualified by a schema (e.g. ops.migrate)
+func validateTable(tableName string) error {
+	parts := strings.Split(tableName, ".")
+	if len(parts) > 2 {
+		return fmt.Errorf("invalid table name %q, expected table or schema.table", tableName)
+	}
+	for _, part := range parts {
+		if !reIdentifier.MatchString(part) {
+			return fmt.Errorf("invalid table name %q, names may only contain letters, digits and underscores", tableName)
+		}
+	}
+	return nil
+}
+
+// quote a table name for use in SQL, quoting the schema and table separately
+func quote(tableName string) string {
+	schema, table := splitTable(tableName)
+	if schema == "" {
+		return quoteIdentifier(table)
+	}
+	return quoteIdentifier(schema) + "." + quoteIdentifier(table)
+}
+
+// quoteIdentifier quotes a single identifier. Double quotes work in both
+// Postgres and SQLite.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// splitTable splits a table name into its schema and table. The schema is
+// empty when the table name isn't qualified. The table is lowercased, the way
+// Postgres folds unquoted names, so --table Migrate still refers to the
+// migrate table that older versions of migrate created without quotes.
+func splitTable(tableName string) (schema, table string) {
+	if i := strings.LastIndex(tableName, "."); i >= 0 {
+		return tableName[:i], strings.ToLower(tableName[i+1:])
+	}
+	return "", strings.ToLower(tableName)
+}
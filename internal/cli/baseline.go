@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+)
+
+type baseline struct {
+	Version int
+	Force   bool
+}
+
+func (in *baseline) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("baseline", "record migrations as applied without running them")
+	cmd.Flag("force", "overwrite the recorded versions").Bool(&in.Force).Default(false)
+	cmd.Arg("version", "mark migrations up to this version as applied").Int(&in.Version)
+	return cmd
+}
+
+func (c *CLI) Baseline(ctx context.Context, in *baseline) error {
+	if in.Version <= 0 {
+		return errors.New("version must be greater than 0")
+	}
+
+	// Connect to the database
+	db, err := c.dialDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	fsys, err := c.migrateFs()
+	if err != nil {
+		return err
+	}
+
+	return migrate.Baseline(log, db, fsys, c.table(), uint(in.Version), in.Force, c.options()...)
+}
@@ -9,9 +9,11 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/livebud/cli"
 	"github.com/matthewmueller/logs"
+	"github.com/matthewmueller/migrate"
 	"github.com/matthewmueller/migrate/internal/db"
 
 	// supported libraries
@@ -51,6 +53,21 @@ type CLI struct {
 	migrateDir string
 	tableName  string
 	dbUrl      string
+	seedsDir   string
+	sources    map[string]string
+	namespace  string
+	schema     string
+	searchPath []string
+	configPath string
+	env        string
+	protected  bool
+	yesIMeanIt bool
+	dotenv     bool
+	wait       string
+	dumpSchema bool
+
+	// isTerminal is swapped out in tests
+	isTerminal func(io.Reader) bool
 }
 
 func (c *CLI) dialDb() (*sql.DB, error) {
@@ -68,6 +85,22 @@ func (c *CLI) log() (*logs.Logger, error) {
 	return logs.New(logs.Filter(lvl, logs.Console(c.Stderr))), nil
 }
 
+// table returns the migrate table, qualified by --schema when it's set
+func (c *CLI) table() string {
+	if c.schema == "" {
+		return c.tableName
+	}
+	return c.schema + "." + c.tableName
+}
+
+// options passed to each migrate call
+func (c *CLI) options() []migrate.Option {
+	return []migrate.Option{
+		migrate.WithNamespace(c.namespace),
+		migrate.WithSearchPath(c.searchPath...),
+	}
+}
+
 // findFirstDir returns the first directory that exists in the list of paths
 func findFirstDir(paths ...string) (string, error) {
 	for _, path := range paths {
@@ -107,7 +140,29 @@ func (c *CLI) findMigrateDir() (string, error) {
 	)
 }
 
+func (c *CLI) findSeedsDir() (string, error) {
+	// First check if the user provided a directory
+	if c.seedsDir != "" {
+		seedsDir := resolveDir(c.Dir, c.seedsDir)
+		if _, err := os.Stat(seedsDir); err != nil {
+			if os.IsNotExist(err) {
+				return "", fmt.Errorf("%s/ directory doesn't exist", seedsDir)
+			}
+			return "", err
+		}
+		return seedsDir, nil
+	}
+	// Otherwise look in a few other default locations
+	return findFirstDir(
+		filepath.Join(c.Dir, "migrate", "seeds"),
+		filepath.Join(c.Dir, "internal", "migrate", "seeds"),
+	)
+}
+
 func (c *CLI) migrateFs() (fs.FS, error) {
+	if len(c.sources) > 0 {
+		return c.sourcesFs()
+	}
 	migrateDir, err := c.findMigrateDir()
 	if err != nil {
 		return nil, err
@@ -115,47 +170,139 @@ func (c *CLI) migrateFs() (fs.FS, error) {
 	return os.DirFS(migrateDir), nil
 }
 
+// sourcesFs merges the named --source directories, ordered by name
+func (c *CLI) sourcesFs() (fs.FS, error) {
+	names := make([]string, 0, len(c.sources))
+	for name := range c.sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sources := make([]migrate.Source, len(names))
+	for i, name := range names {
+		dir := resolveDir(c.Dir, c.sources[name])
+		if _, err := os.Stat(dir); err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("%s/ directory doesn't exist", dir)
+			}
+			return nil, err
+		}
+		sources[i] = migrate.Source{Name: name, FS: os.DirFS(dir)}
+	}
+	return migrate.Sources(sources...), nil
+}
+
+// run configures the CLI and waits for the database before running the
+// command
+func (c *CLI) run(fn func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := c.configure(); err != nil {
+			return err
+		}
+		if err := c.waitForDb(ctx); err != nil {
+			return err
+		}
+		return fn(ctx)
+	}
+}
+
 func (c *CLI) Parse(ctx context.Context, args ...string) error {
 	cli := cli.New("migrate", "No frills database migration CLI for Postgres & SQLite")
+	cli.Flag("config", "configuration file (default: .migrate.json or migrate.json)").String(&c.configPath).Default("")
+	cli.Flag("env", "environment in the configuration file").Env("MIGRATE_ENV").String(&c.env).Default("")
+	cli.Flag("dotenv", "load .env and .env.<env> from the project directory").Bool(&c.dotenv).Default(false)
+	cli.Flag("yes-i-mean-it", "skip the confirmation for protected environments").Bool(&c.yesIMeanIt).Default(false)
 	cli.Flag("log", "log level").Enum(&c.logLevel, "debug", "info", "warn", "error").Default("info")
 	cli.Flag("dir", "migrations directory").String(&c.migrateDir).Default("")
-	cli.Flag("table", "table name").String(&c.tableName).Default("migrate")
+	cli.Flag("source", "named migrations directory (name:dir)").StringMap(&c.sources).Default(map[string]string{})
+	cli.Flag("table", "table name (default: migrate)").String(&c.tableName).Default("")
+	cli.Flag("schema", "schema of the migrate table").String(&c.schema).Default("")
+	cli.Flag("search-path", "postgres search_path while migrating").Strings(&c.searchPath).Default()
+	cli.Flag("namespace", "track versions under a namespace").String(&c.namespace).Default("")
 	cli.Flag("db", "database connection string").Env("DATABASE_URL").String(&c.dbUrl).Default("")
+	cli.Flag("wait", "wait up to this long for the database to accept connections (e.g. 30s)").String(&c.wait).Default("")
 
 	{ // New
 		in := &newIn{}
 		cmd := in.Command(cli)
-		cmd.Run(func(ctx context.Context) error { return c.New(ctx, in) })
+		cmd.Run(c.run(func(ctx context.Context) error { return c.New(ctx, in) }))
 	}
 
 	{ // Up
 		in := &up{}
 		cmd := in.Command(cli)
-		cmd.Run(func(ctx context.Context) error { return c.Up(ctx, in) })
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Up(ctx, in) }))
 	}
 
 	{ // Down
 		in := &down{}
 		cmd := in.Command(cli)
-		cmd.Run(func(ctx context.Context) error { return c.Down(ctx, in) })
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Down(ctx, in) }))
 	}
 
 	{ // Reset
 		in := &reset{}
 		cmd := in.Command(cli)
-		cmd.Run(func(ctx context.Context) error { return c.Reset(ctx, in) })
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Reset(ctx, in) }))
 	}
 
 	{ // Redo
 		in := &redo{}
 		cmd := in.Command(cli)
-		cmd.Run(func(ctx context.Context) error { return c.Redo(ctx, in) })
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Redo(ctx, in) }))
+	}
+
+	{ // Dump
+		in := &dump{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Dump(ctx, in) }))
+	}
+
+	{ // Squash
+		in := &squash{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Squash(ctx, in) }))
+	}
+
+	{ // Baseline
+		in := &baseline{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Baseline(ctx, in) }))
+	}
+
+	{ // Force
+		in := &force{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Force(ctx, in) }))
+	}
+
+	{ // Seed
+		in := &seed{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Seed(ctx, in) }))
+	}
+
+	{ // Renumber
+		in := &renumber{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Renumber(ctx, in) }))
+	}
+
+	{ // CheckReversible
+		in := &checkReversible{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.CheckReversible(ctx, in) }))
+	}
+
+	{ // Tenants
+		in := &tenants{}
+		cmd := in.Command(cli)
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Tenants(ctx, in) }))
 	}
 
 	{ // Info
 		in := &info{}
 		cmd := in.Command(cli)
-		cmd.Run(func(ctx context.Context) error { return c.Info(ctx, in) })
+		cmd.Run(c.run(func(ctx context.Context) error { return c.Info(ctx, in) }))
 	}
 
 	{ // Version
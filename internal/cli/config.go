@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// configFiles are the project configuration files that migrate looks for in
+// the project directory, in order
+var configFiles = []string{".migrate.json", "migrate.json"}
+
+// profile is a set of settings within the configuration file. Relative
+// directories are relative to the configuration file.
+type profile struct {
+	Dir       string `json:"dir,omitempty"`
+	Seeds     string `json:"seeds,omitempty"`
+	Table     string `json:"table,omitempty"`
+	Schema    string `json:"schema,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	DB        string `json:"db,omitempty"`
+	// Dump rewrites schema.sql after every up, e.g. in development
+	Dump *bool `json:"dump,omitempty"`
+	// Protected environments confirm before running down, reset, redo or force
+	Protected bool `json:"protected,omitempty"`
+}
+
+// config is the project configuration file. The top-level settings apply to
+// every environment, named environments are selected with --env and override
+// the top-level settings.
+//
+//	{
+//	  "dir": "internal/migrate",
+//	  "db": "postgres://localhost:5432/app",
+//	  "env": {
+//	    "production": { "db": "postgres://db.internal:5432/app", "protected": true }
+//	  }
+//	}
+type config struct {
+	profile
+	Env map[string]*profile `json:"env,omitempty"`
+	// Dotenv loads .env and .env.<env> from the project directory
+	Dotenv bool `json:"dotenv,omitempty"`
+}
+
+// findConfig returns the path to the configuration file. An empty path means
+// there's no configuration file.
+func (c *CLI) findConfig() (string, error) {
+	if c.configPath != "" {
+		return resolveDir(c.Dir, c.configPath), nil
+	}
+	for _, name := range configFiles {
+		path := filepath.Join(c.Dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// loadConfig reads the configuration file
+func loadConfig(path string) (*config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := new(config)
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// configure fills in the settings that weren't passed as flags from the
+// environment, then the configuration file and then the built-in defaults.
+func (c *CLI) configure() error {
+	path, err := c.findConfig()
+	if err != nil {
+		return err
+	}
+	config := new(config)
+	if path != "" {
+		if config, err = loadConfig(path); err != nil {
+			return err
+		}
+	} else if c.env != "" {
+		return fmt.Errorf("unable to use --env %q without a configuration file (%s)", c.env, strings.Join(configFiles, " or "))
+	}
+	profile, err := config.profileFor(c.env)
+	if err != nil {
+		return fmt.Errorf("%w in %s", err, path)
+	}
+	// real environment variables
+	if c.dbUrl == "" {
+		if c.dbUrl, err = c.databaseURL(c.environ()); err != nil {
+			return err
+		}
+	}
+	// the database of the selected environment wins over the generic .env
+	if c.dbUrl == "" && c.env != "" {
+		c.dbUrl = config.Env[c.env].DB
+	}
+	// .env files
+	if c.dbUrl == "" && (c.dotenv || config.Dotenv) {
+		env, err := c.loadDotenv()
+		if err != nil {
+			return err
+		}
+		if c.dbUrl, err = c.databaseURL(env); err != nil {
+			return err
+		}
+	}
+	// configuration file
+	if err := c.apply(filepath.Dir(path), profile); err != nil {
+		return err
+	}
+	// built-in defaults
+	if c.tableName == "" {
+		c.tableName = "migrate"
+	}
+	return nil
+}
+
+// profileFor merges the named environment into the top-level settings
+func (c *config) profileFor(env string) (*profile, error) {
+	merged := c.profile
+	if env == "" {
+		return &merged, nil
+	}
+	override, ok := c.Env[env]
+	if !ok {
+		return nil, fmt.Errorf("unknown env %q, expected one of %s", env, strings.Join(c.envNames(), ", "))
+	}
+	if override.Dir != "" {
+		merged.Dir = override.Dir
+	}
+	if override.Seeds != "" {
+		merged.Seeds = override.Seeds
+	}
+	if override.Table != "" {
+		merged.Table = override.Table
+	}
+	if override.Schema != "" {
+		merged.Schema = override.Schema
+	}
+	if override.Namespace != "" {
+		merged.Namespace = override.Namespace
+	}
+	if override.DB != "" {
+		merged.DB = override.DB
+	}
+	if override.Dump != nil {
+		merged.Dump = override.Dump
+	}
+	merged.Protected = merged.Protected || override.Protected
+	return &merged, nil
+}
+
+// envNames returns the sorted environment names
+func (c *config) envNames() (names []string) {
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apply the profile to the settings that haven't been set by flags
+func (c *CLI) apply(base string, profile *profile) error {
+	var err error
+	if c.migrateDir == "" && profile.Dir != "" {
+		if c.migrateDir, err = absDir(base, profile.Dir); err != nil {
+			return err
+		}
+	}
+	if c.seedsDir == "" && profile.Seeds != "" {
+		if c.seedsDir, err = absDir(base, profile.Seeds); err != nil {
+			return err
+		}
+	}
+	if c.tableName == "" {
+		c.tableName = profile.Table
+	}
+	if c.schema == "" {
+		c.schema = profile.Schema
+	}
+	if c.namespace == "" {
+		c.namespace = profile.Namespace
+	}
+	if c.dbUrl == "" {
+		c.dbUrl = profile.DB
+	}
+	if profile.Dump != nil {
+		c.dumpSchema = *profile.Dump
+	}
+	c.protected = profile.Protected
+	return nil
+}
+
+// absDir resolves dir relative to base
+func absDir(base, dir string) (string, error) {
+	return filepath.Abs(resolveDir(base, dir))
+}
@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestProfileFor(t *testing.T) {
+	config := &config{
+		profile: profile{
+			Dir:   "migrate",
+			Table: "migrate",
+			DB:    "sqlite://dev.db",
+		},
+		Env: map[string]*profile{
+			"production": {
+				DB:        "postgres://db.internal:5432/app",
+				Schema:    "ops",
+				Protected: true,
+			},
+			"test": {
+				Dir:       "testdata/migrate",
+				Seeds:     "testdata/seeds",
+				Table:     "versions",
+				Namespace: "test",
+			},
+		},
+	}
+	tests := []struct {
+		name   string
+		env    string
+		expect *profile
+		err    string
+	}{
+		{
+			name:   "top-level",
+			expect: &profile{Dir: "migrate", Table: "migrate", DB: "sqlite://dev.db"},
+		},
+		{
+			name: "production",
+			env:  "production",
+			expect: &profile{
+				Dir:       "migrate",
+				Table:     "migrate",
+				Schema:    "ops",
+				DB:        "postgres://db.internal:5432/app",
+				Protected: true,
+			},
+		},
+		{
+			name: "test",
+			env:  "test",
+			expect: &profile{
+				Dir:       "testdata/migrate",
+				Seeds:     "testdata/seeds",
+				Table:     "versions",
+				Namespace: "test",
+				DB:        "sqlite://dev.db",
+			},
+		},
+		{
+			name: "unknown",
+			env:  "staging",
+			err:  `unknown env "staging", expected one of production, test`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			profile, err := config.profileFor(test.env)
+			if test.err != "" {
+				is.True(err != nil)
+				is.Equal(err.Error(), test.err)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(profile, test.expect)
+		})
+	}
+	// merging doesn't change the top-level settings
+	is := is.New(t)
+	is.Equal(config.profile.DB, "sqlite://dev.db")
+	is.Equal(config.profile.Protected, false)
+}
+
+func TestApply(t *testing.T) {
+	is := is.New(t)
+	base := t.TempDir()
+	c := &CLI{tableName: "flag", dbUrl: "sqlite://flag.db"}
+	is.NoErr(c.apply(base, &profile{
+		Dir:       "internal/migrate",
+		Seeds:     "/abs/seeds",
+		Table:     "file",
+		Schema:    "ops",
+		Namespace: "billing",
+		DB:        "sqlite://file.db",
+		Protected: true,
+	}))
+	is.Equal(c.migrateDir, filepath.Join(base, "internal/migrate"))
+	is.Equal(c.seedsDir, "/abs/seeds")
+	is.Equal(c.tableName, "flag")
+	is.Equal(c.schema, "ops")
+	is.Equal(c.namespace, "billing")
+	is.Equal(c.dbUrl, "sqlite://flag.db")
+	is.Equal(c.protected, true)
+}
+
+func TestConfigure(t *testing.T) {
+	config := `{
+		"dir": "db/migrate",
+		"seeds": "db/seeds",
+		"table": "versions",
+		"db": "sqlite://dev.db",
+		"env": {
+			"production": { "db": "sqlite://production.db", "schema": "ops", "protected": true }
+		}
+	}`
+	tests := []struct {
+		name   string
+		files  map[string]string
+		config string // --config
+		cli    CLI    // flags
+		expect CLI
+		err    string
+	}{
+		{
+			name: "defaults",
+			expect: CLI{
+				tableName: "migrate",
+			},
+		},
+		{
+			name:  "file",
+			files: map[string]string{"migrate.json": config},
+			expect: CLI{
+				migrateDir: "db/migrate",
+				seedsDir:   "db/seeds",
+				tableName:  "versions",
+				dbUrl:      "sqlite://dev.db",
+			},
+		},
+		{
+			name:  "dotfile wins over migrate.json",
+			files: map[string]string{".migrate.json": `{"table": "dotfile"}`, "migrate.json": config},
+			expect: CLI{
+				tableName: "dotfile",
+			},
+		},
+		{
+			name:  "flags win over file",
+			files: map[string]string{"migrate.json": config},
+			cli: CLI{
+				migrateDir: "flag/migrate",
+				tableName:  "flag",
+				dbUrl:      "sqlite://flag.db",
+			},
+			expect: CLI{
+				migrateDir: "flag/migrate",
+				seedsDir:   "db/seeds",
+				tableName:  "flag",
+				dbUrl:      "sqlite://flag.db",
+			},
+		},
+		{
+			name:  "env merges into the top-level settings",
+			files: map[string]string{"migrate.json": config},
+			cli:   CLI{env: "production"},
+			expect: CLI{
+				migrateDir: "db/migrate",
+				seedsDir:   "db/seeds",
+				tableName:  "versions",
+				schema:     "ops",
+				dbUrl:      "sqlite://production.db",
+				env:        "production",
+				protected:  true,
+			},
+		},
+		{
+			name:   "directories are relative to the --config file",
+			files:  map[string]string{"config/migrate.json": config},
+			config: "config/migrate.json",
+			expect: CLI{
+				migrateDir: "config/db/migrate",
+				seedsDir:   "config/db/seeds",
+				tableName:  "versions",
+				dbUrl:      "sqlite://dev.db",
+			},
+		},
+		{
+			name: "env without a file",
+			cli:  CLI{env: "production"},
+			err:  `unable to use --env "production" without a configuration file (.migrate.json or migrate.json)`,
+		},
+		{
+			name:  "unknown env",
+			files: map[string]string{"migrate.json": config},
+			cli:   CLI{env: "staging"},
+			err:   `unknown env "staging", expected one of production in `,
+		},
+		{
+			name:  "invalid file",
+			files: map[string]string{"migrate.json": `{"dir": 1}`},
+			err:   "unable to parse ",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, test.files)
+			c := test.cli
+			c.Dir = dir
+			c.configPath = test.config
+			err := c.configure()
+			if test.err != "" {
+				is.True(err != nil)
+				is.True(strings.HasPrefix(err.Error(), test.err))
+				return
+			}
+			is.NoErr(err)
+			// directories from the file are absolute, flags are left as is
+			expect := test.expect
+			for _, dir := range []*string{&expect.migrateDir, &expect.seedsDir} {
+				if *dir != "" && !strings.HasPrefix(*dir, "flag/") {
+					*dir = filepath.Join(c.Dir, *dir)
+				}
+			}
+			is.Equal(c.migrateDir, expect.migrateDir)
+			is.Equal(c.seedsDir, expect.seedsDir)
+			is.Equal(c.tableName, expect.tableName)
+			is.Equal(c.schema, expect.schema)
+			is.Equal(c.namespace, expect.namespace)
+			is.Equal(c.dbUrl, expect.dbUrl)
+			is.Equal(c.protected, expect.protected)
+		})
+	}
+}
+
+func TestDumpSetting(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		args   []string
+		dumped bool
+	}{
+		{"off", `{}`, []string{"up"}, false},
+		{"flag", `{}`, []string{"up", "--dump"}, true},
+		{"file", `{"dump": true}`, []string{"up"}, true},
+		{"env turns it off", `{"dump": true, "env": {"production": {"dump": false}}}`, []string{"--env", "production", "up"}, false},
+		{"env turns it on", `{"env": {"development": {"dump": true}}}`, []string{"--env", "development", "up"}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"migrate.json":               test.config,
+				"migrate/001_teams.up.sql":   `create table teams (id integer primary key not null);`,
+				"migrate/001_teams.down.sql": `drop table if exists teams;`,
+			})
+			c := &CLI{Stdout: io.Discard, Stderr: io.Discard, Dir: dir}
+			args := append([]string{"--db", "sqlite:" + filepath.Join(dir, "app.db")}, test.args...)
+			is.NoErr(c.Parse(context.Background(), args...))
+			_, err := os.Stat(filepath.Join(dir, "migrate", "schema.sql"))
+			is.Equal(err == nil, test.dumped)
+		})
+	}
+}
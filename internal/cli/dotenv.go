@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// environ returns the real environment variables
+func (c *CLI) environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range c.Env {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// loadDotenv returns the variables in .env and .env.<env> from the project
+// directory. Variables in .env.<env> take precedence over .env.
+func (c *CLI) loadDotenv() (map[string]string, error) {
+	env := map[string]string{}
+	files := []string{".env"}
+	if c.env != "" {
+		files = append(files, ".env."+c.env)
+	}
+	for _, name := range files {
+		vars, err := readDotenv(filepath.Join(c.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		overlay(env, vars)
+	}
+	return env, nil
+}
+
+// overlay vars onto env. Setting $DATABASE_URL_FILE replaces $DATABASE_URL
+// from a lower level, so each level's choice of the two is respected.
+func overlay(env, vars map[string]string) {
+	if _, ok := vars["DATABASE_URL_FILE"]; ok {
+		if _, ok := vars["DATABASE_URL"]; !ok {
+			delete(env, "DATABASE_URL")
+		}
+	}
+	for key, value := range vars {
+		env[key] = value
+	}
+}
+
+// readDotenv parses a .env file. Missing files are ignored.
+func readDotenv(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		vars[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// unquote removes matching single or double quotes around a value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// databaseURL finds the database URL in the environment. $DATABASE_URL takes
+// precedence over $DATABASE_URL_FILE, which points to a file containing the
+// URL (e.g. a mounted secret).
+func (c *CLI) databaseURL(env map[string]string) (string, error) {
+	if url := env["DATABASE_URL"]; url != "" {
+		return url, nil
+	}
+	path := env["DATABASE_URL_FILE"]
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(resolveDir(c.Dir, path))
+	if err != nil {
+		return "", fmt.Errorf("unable to read $DATABASE_URL_FILE: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// writeFiles writes files into dir
+func writeFiles(t testing.TB, dir string, files map[string]string) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadDotenv(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		expect map[string]string
+		err    string
+	}{
+		{
+			name:   "plain",
+			data:   "DATABASE_URL=postgres://localhost:5432/app\n",
+			expect: map[string]string{"DATABASE_URL": "postgres://localhost:5432/app"},
+		},
+		{
+			name:   "comments and blank lines",
+			data:   "# database\n\nDATABASE_URL=sqlite://app.db\n  # indented\n",
+			expect: map[string]string{"DATABASE_URL": "sqlite://app.db"},
+		},
+		{
+			name:   "export",
+			data:   "export DATABASE_URL=sqlite://app.db\n",
+			expect: map[string]string{"DATABASE_URL": "sqlite://app.db"},
+		},
+		{
+			name: "quotes",
+			data: "A=\"double\"\nB='single'\nC=\"mismatched'\nD=\"\"\n",
+			expect: map[string]string{
+				"A": "double",
+				"B": "single",
+				"C": "\"mismatched'",
+				"D": "",
+			},
+		},
+		{
+			name:   "spaces around the equals sign",
+			data:   "DATABASE_URL = sqlite://app.db \n",
+			expect: map[string]string{"DATABASE_URL": "sqlite://app.db"},
+		},
+		{
+			name:   "equals sign in the value",
+			data:   "DATABASE_URL=postgres://localhost/app?sslmode=disable\n",
+			expect: map[string]string{"DATABASE_URL": "postgres://localhost/app?sslmode=disable"},
+		},
+		{
+			name: "missing equals sign",
+			data: "DATABASE_URL=sqlite://app.db\nDATABASE_URL_FILE\n",
+			err:  ":2: expected KEY=VALUE",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			dir := t.TempDir()
+			path := filepath.Join(dir, ".env")
+			writeFiles(t, dir, map[string]string{".env": test.data})
+			vars, err := readDotenv(path)
+			if test.err != "" {
+				is.True(err != nil)
+				is.Equal(err.Error(), path+test.err)
+				return
+			}
+			is.NoErr(err)
+			is.Equal(vars, test.expect)
+		})
+	}
+	t.Run("missing file", func(t *testing.T) {
+		is := is.New(t)
+		vars, err := readDotenv(filepath.Join(t.TempDir(), ".env"))
+		is.NoErr(err)
+		is.Equal(vars, nil)
+	})
+}
+
+func TestOverlay(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		vars   map[string]string
+		expect map[string]string
+	}{
+		{
+			name:   "override",
+			env:    map[string]string{"DATABASE_URL": "a", "OTHER": "b"},
+			vars:   map[string]string{"DATABASE_URL": "c"},
+			expect: map[string]string{"DATABASE_URL": "c", "OTHER": "b"},
+		},
+		{
+			name:   "file replaces a lower url",
+			env:    map[string]string{"DATABASE_URL": "a"},
+			vars:   map[string]string{"DATABASE_URL_FILE": "secret"},
+			expect: map[string]string{"DATABASE_URL_FILE": "secret"},
+		},
+		{
+			name:   "url and file at the same level",
+			env:    map[string]string{"DATABASE_URL": "a"},
+			vars:   map[string]string{"DATABASE_URL": "b", "DATABASE_URL_FILE": "secret"},
+			expect: map[string]string{"DATABASE_URL": "b", "DATABASE_URL_FILE": "secret"},
+		},
+		{
+			name:   "url keeps a lower file",
+			env:    map[string]string{"DATABASE_URL_FILE": "secret"},
+			vars:   map[string]string{"DATABASE_URL": "a"},
+			expect: map[string]string{"DATABASE_URL": "a", "DATABASE_URL_FILE": "secret"},
+		},
+		{
+			name:   "nil vars",
+			env:    map[string]string{"DATABASE_URL": "a"},
+			expect: map[string]string{"DATABASE_URL": "a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			overlay(test.env, test.vars)
+			is.Equal(test.env, test.expect)
+		})
+	}
+}
+
+func TestDatabaseURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		env    map[string]string
+		expect string
+		err    string
+	}{
+		{
+			name:   "empty",
+			env:    map[string]string{},
+			expect: "",
+		},
+		{
+			name:   "url",
+			env:    map[string]string{"DATABASE_URL": "sqlite://app.db"},
+			expect: "sqlite://app.db",
+		},
+		{
+			name:   "file relative to the project",
+			files:  map[string]string{"secrets/db": "postgres://localhost:5432/app\n"},
+			env:    map[string]string{"DATABASE_URL_FILE": "secrets/db"},
+			expect: "postgres://localhost:5432/app",
+		},
+		{
+			name:   "url wins over file",
+			files:  map[string]string{"secrets/db": "postgres://localhost:5432/app"},
+			env:    map[string]string{"DATABASE_URL": "sqlite://app.db", "DATABASE_URL_FILE": "secrets/db"},
+			expect: "sqlite://app.db",
+		},
+		{
+			name: "missing file",
+			env:  map[string]string{"DATABASE_URL_FILE": "secrets/db"},
+			err:  "unable to read $DATABASE_URL_FILE",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, test.files)
+			c := &CLI{Dir: dir}
+			url, err := c.databaseURL(test.env)
+			if test.err != "" {
+				is.True(err != nil)
+				is.True(strings.HasPrefix(err.Error(), test.err))
+				return
+			}
+			is.NoErr(err)
+			is.Equal(url, test.expect)
+		})
+	}
+}
+
+func TestDatabaseURLPrecedence(t *testing.T) {
+	config := `{
+		"db": "sqlite://config.db",
+		"dotenv": true,
+		"env": {
+			"production": { "db": "sqlite://production.db" },
+			"staging": { "table": "staging" }
+		}
+	}`
+	tests := []struct {
+		name    string
+		files   map[string]string
+		env     string
+		dbUrl   string
+		environ []string
+		expect  string
+	}{
+		{
+			name:   "config",
+			files:  map[string]string{"migrate.json": config},
+			expect: "sqlite://config.db",
+		},
+		{
+			name:   ".env over config",
+			files:  map[string]string{"migrate.json": config, ".env": "DATABASE_URL=sqlite://dotenv.db"},
+			expect: "sqlite://dotenv.db",
+		},
+		{
+			name: ".env.<env> over .env",
+			files: map[string]string{
+				"migrate.json": config,
+				".env":         "DATABASE_URL=sqlite://dotenv.db",
+				".env.staging": "DATABASE_URL=sqlite://dotenv-staging.db",
+			},
+			env:    "staging",
+			expect: "sqlite://dotenv-staging.db",
+		},
+		{
+			name: "selected env over .env",
+			files: map[string]string{
+				"migrate.json":    config,
+				".env":            "DATABASE_URL=sqlite://dotenv.db",
+				".env.production": "DATABASE_URL=sqlite://dotenv-production.db",
+			},
+			env:    "production",
+			expect: "sqlite://production.db",
+		},
+		{
+			name:    "real environment over selected env",
+			files:   map[string]string{"migrate.json": config, ".env": "DATABASE_URL=sqlite://dotenv.db"},
+			env:     "production",
+			environ: []string{"DATABASE_URL=sqlite://environ.db"},
+			expect:  "sqlite://environ.db",
+		},
+		{
+			name:    "flag over everything",
+			files:   map[string]string{"migrate.json": config, ".env": "DATABASE_URL=sqlite://dotenv.db"},
+			env:     "production",
+			dbUrl:   "sqlite://flag.db",
+			environ: []string{"DATABASE_URL=sqlite://environ.db"},
+			expect:  "sqlite://flag.db",
+		},
+		{
+			name: "file in .env.<env> replaces the url in .env",
+			files: map[string]string{
+				"migrate.json": config,
+				".env":         "DATABASE_URL=sqlite://dotenv.db",
+				".env.staging": "DATABASE_URL_FILE=secret",
+				"secret":       "sqlite://secret.db",
+			},
+			env:    "staging",
+			expect: "sqlite://secret.db",
+		},
+		{
+			name:   "dotenv disabled",
+			files:  map[string]string{"migrate.json": `{"db": "sqlite://config.db"}`, ".env": "DATABASE_URL=sqlite://dotenv.db"},
+			expect: "sqlite://config.db",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := is.New(t)
+			dir := t.TempDir()
+			writeFiles(t, dir, test.files)
+			c := &CLI{Dir: dir, Env: test.environ, env: test.env, dbUrl: test.dbUrl}
+			is.NoErr(c.configure())
+			is.Equal(c.dbUrl, test.expect)
+		})
+	}
+}
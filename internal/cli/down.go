@@ -18,6 +18,10 @@ func (in *down) Command(cmd cli.Command) cli.Command {
 }
 
 func (c *CLI) Down(ctx context.Context, in *down) error {
+	if err := c.confirm("roll back"); err != nil {
+		return err
+	}
+
 	// Connect to the database
 	db, err := c.dialDb()
 	if err != nil {
@@ -38,9 +42,9 @@ func (c *CLI) Down(ctx context.Context, in *down) error {
 	// be a bit extra careful here
 	switch {
 	case in.N == nil:
-		return migrate.Down(log, db, fsys, c.tableName)
+		return migrate.Down(log, db, fsys, c.table(), c.options()...)
 	case *in.N > 0:
-		return migrate.DownBy(log, db, fsys, c.tableName, *in.N)
+		return migrate.DownBy(log, db, fsys, c.table(), *in.N, c.options()...)
 	}
 	return nil
 }
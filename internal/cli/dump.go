@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+)
+
+type dump struct {
+	Out string
+}
+
+func (in *dump) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("dump", "dump the database schema to schema.sql")
+	cmd.Flag("out", "schema file (defaults to schema.sql in the migrations directory)").String(&in.Out).Default("")
+	return cmd
+}
+
+func (c *CLI) Dump(ctx context.Context, in *dump) error {
+	// Connect to the database
+	db, err := c.dialDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	path, err := c.schemaFile(in.Out)
+	if err != nil {
+		return err
+	}
+	if err := c.dump(db, path); err != nil {
+		return err
+	}
+	log.Info("wrote: " + path)
+	return nil
+}
+
+// schemaFile resolves where to write the schema dump
+func (c *CLI) schemaFile(out string) (string, error) {
+	if out != "" {
+		return resolveDir(c.Dir, out), nil
+	}
+	migrateDir, err := c.findMigrateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(migrateDir, "schema.sql"), nil
+}
+
+// dump the schema to path
+func (c *CLI) dump(db *sql.DB, path string) error {
+	schema, err := migrate.Dump(db, c.table(), c.options()...)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(schema), 0644)
+}
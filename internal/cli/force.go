@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+)
+
+type force struct {
+	Version int
+}
+
+func (in *force) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("force", "set the version after repairing a dirty database")
+	cmd.Arg("version", "version to record").Int(&in.Version)
+	return cmd
+}
+
+func (c *CLI) Force(ctx context.Context, in *force) error {
+	if in.Version < 0 {
+		return errors.New("version can't be negative")
+	}
+
+	if err := c.confirm("force"); err != nil {
+		return err
+	}
+
+	// Connect to the database
+	db, err := c.dialDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	return migrate.Force(log, db, c.table(), uint(in.Version), c.options()...)
+}
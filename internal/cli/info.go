@@ -35,14 +35,14 @@ func (c *CLI) Info(ctx context.Context, in *info) error {
 	}
 
 	local, err := migrate.LocalVersion(fsys)
-	if err == migrate.ErrNoMigrations {
+	if errors.Is(err, migrate.ErrNoMigrations) {
 		return errors.New("no local migrations yet")
 	} else if err != nil {
 		return err
 	}
 
-	remote, err := migrate.RemoteVersion(db, fsys, c.tableName)
-	if err == migrate.ErrNoMigrations {
+	remote, err := migrate.RemoteVersion(db, fsys, c.table(), c.options()...)
+	if errors.Is(err, migrate.ErrNoMigrations) {
 		return errors.New("no remote migrations yet")
 	} else if err != nil {
 		return err
@@ -50,5 +50,24 @@ func (c *CLI) Info(ctx context.Context, in *info) error {
 
 	log.Info("local: " + local)
 	log.Info("remote: " + remote)
+
+	set, err := migrate.Load(fsys)
+	if err != nil {
+		return err
+	}
+	statuses, err := migrate.Status(db, fsys, c.table(), c.options()...)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		attrs := []any{"applied", status.Applied}
+		if set.Find(status.Version).Irreversible() {
+			attrs = append(attrs, "irreversible", true)
+		}
+		if status.Source != "" {
+			attrs = append(attrs, "source", status.Source)
+		}
+		log.Info(status.Name, attrs...)
+	}
 	return nil
 }
@@ -3,21 +3,28 @@ package cli
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
 
 	"github.com/Bowery/prompt"
 	"github.com/livebud/cli"
 	"github.com/matthewmueller/migrate"
+	"github.com/matthewmueller/migrate/internal/db"
 	"github.com/matthewmueller/virt"
+	"github.com/xo/dburl"
 )
 
 type newIn struct {
 	Name string
+	Diff string
 }
 
 func (in *newIn) Command(cmd cli.Command) cli.Command {
 	cmd = cmd.Command("new", "create a new migration")
+	cmd.Flag("diff", "generate the migration from a desired schema file").String(&in.Diff).Default("")
 	cmd.Arg("name", "create a new migration by name").String(&in.Name).Default("")
 	return cmd
 }
@@ -46,5 +53,62 @@ func (c *CLI) New(ctx context.Context, in *newIn) (err error) {
 		return err
 	}
 
-	return migrate.New(log, virt.OS(migrateDir), in.Name)
+	var paths []string
+	if in.Diff != "" {
+		paths, err = c.newDiff(log, migrateDir, in)
+	} else {
+		paths, err = migrate.New(log, virt.OS(migrateDir), in.Name, migrate.WithDialect(c.dialect()))
+	}
+	if err != nil {
+		return err
+	}
+
+	// print the paths so editors can open them
+	for _, path := range paths {
+		fmt.Fprintln(c.Stdout, filepath.Join(migrateDir, path))
+	}
+	return nil
+}
+
+// dialect of the database, defaulting to postgres
+func (c *CLI) dialect() string {
+	if u, err := dburl.Parse(c.dbUrl); err == nil && u.Driver == "sqlite3" {
+		return "sqlite"
+	}
+	return "postgres"
+}
+
+// newDiff applies the existing migrations and the desired schema to two
+// throwaway databases, then writes the migration between them
+func (c *CLI) newDiff(log *slog.Logger, migrateDir string, in *newIn) (paths []string, err error) {
+	if c.dbUrl == "" {
+		return nil, errors.New("missing --db or $DATABASE_URL environment variable")
+	}
+	desiredSQL, err := os.ReadFile(resolveDir(c.Dir, in.Diff))
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrate a throwaway database to the current schema, the same way up
+	// would
+	current, closeCurrent, err := db.Scratch(c.dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, closeCurrent()) }()
+	if err := migrate.Up(nil, current, os.DirFS(migrateDir), c.table(), c.options()...); err != nil && !errors.Is(err, migrate.ErrNoMigrations) {
+		return nil, err
+	}
+
+	// Apply the desired schema to another throwaway database
+	desired, closeDesired, err := db.Scratch(c.dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = errors.Join(err, closeDesired()) }()
+	if _, err := desired.Exec(string(desiredSQL)); err != nil {
+		return nil, err
+	}
+
+	return migrate.NewDiff(log, virt.OS(migrateDir), in.Name, current, desired, c.table())
 }
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Bowery/prompt"
+	"github.com/xo/dburl"
+)
+
+// confirm guards destructive commands against protected environments. The
+// user must type the database name, or pass --yes-i-mean-it when there's no
+// terminal to prompt on.
+func (c *CLI) confirm(command string) error {
+	if !c.protected || c.yesIMeanIt {
+		return nil
+	}
+	name, err := databaseName(c.dbUrl)
+	if err != nil {
+		return err
+	} else if name == "" {
+		return fmt.Errorf("refusing to %s the protected database because the connection string doesn't name one, pass --yes-i-mean-it to continue", command)
+	}
+	if !c.interactive() {
+		return fmt.Errorf("refusing to %s the protected %q database without a terminal, pass --yes-i-mean-it to continue", command, name)
+	}
+	fmt.Fprintf(c.Stderr, "%q is protected. Type the database name to %s it: ", name, command)
+	answer, err := bufio.NewReader(c.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer != name {
+		return fmt.Errorf("%q doesn't match %q, not running %s", answer, name, command)
+	}
+	return nil
+}
+
+// databaseName returns the name of the database in the connection string
+func databaseName(connString string) (string, error) {
+	if connString == "" {
+		return "", fmt.Errorf("missing --db or $DATABASE_URL environment variable")
+	}
+	u, err := dburl.Parse(connString)
+	if err != nil {
+		return "", err
+	}
+	switch u.Driver {
+	case "sqlite3":
+		path, _, _ := strings.Cut(u.DSN, "?")
+		return filepath.Base(path), nil
+	default:
+		if name := strings.TrimPrefix(u.Path, "/"); name != "" {
+			return name, nil
+		}
+		// e.g. postgres://localhost/?dbname=app
+		return u.Query().Get("dbname"), nil
+	}
+}
+
+// interactive returns true if stdin is a terminal that can be prompted on
+func (c *CLI) interactive() bool {
+	if c.isTerminal != nil {
+		return c.isTerminal(c.Stdin)
+	}
+	return isTerminal(c.Stdin)
+}
+
+// isTerminal returns true if r is an interactive terminal
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	_, _, err := prompt.TerminalSize(file)
+	return err == nil
+}
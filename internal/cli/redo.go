@@ -16,6 +16,10 @@ func (in *redo) Command(cmd cli.Command) cli.Command {
 }
 
 func (c *CLI) Redo(ctx context.Context, in *redo) error {
+	if err := c.confirm("redo"); err != nil {
+		return err
+	}
+
 	// Connect to the database
 	db, err := c.dialDb()
 	if err != nil {
@@ -33,5 +37,5 @@ func (c *CLI) Redo(ctx context.Context, in *redo) error {
 		return err
 	}
 
-	return migrate.Redo(log, db, fsys, c.tableName)
+	return migrate.Redo(log, db, fsys, c.table(), c.options()...)
 }
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+	"github.com/matthewmueller/virt"
+)
+
+type renumber struct {
+}
+
+func (in *renumber) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("renumber", "renumber conflicting or out-of-sequence migrations")
+	return cmd
+}
+
+func (c *CLI) Renumber(ctx context.Context, in *renumber) error {
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	migrateDir, err := c.findMigrateDir()
+	if err != nil {
+		return err
+	}
+
+	// Check the database too, if we have one
+	var db *sql.DB
+	if c.dbUrl != "" {
+		if db, err = c.dialDb(); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	renames, err := migrate.Renumber(log, db, virt.OS(migrateDir), c.table(), c.options()...)
+	if err != nil {
+		return err
+	}
+	if len(renames) == 0 {
+		log.Info("nothing to renumber")
+		return nil
+	}
+	log.Info(fmt.Sprintf("renamed %d files", len(renames)))
+	return nil
+}
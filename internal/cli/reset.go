@@ -16,6 +16,10 @@ func (in *reset) Command(cmd cli.Command) cli.Command {
 }
 
 func (c *CLI) Reset(ctx context.Context, in *reset) (err error) {
+	if err := c.confirm("reset"); err != nil {
+		return err
+	}
+
 	// Connect to the database
 	db, err := c.dialDb()
 	if err != nil {
@@ -33,10 +37,10 @@ func (c *CLI) Reset(ctx context.Context, in *reset) (err error) {
 		return err
 	}
 
-	if err := migrate.Down(log, db, fsys, c.tableName); err != nil {
+	if err := migrate.Down(log, db, fsys, c.table(), c.options()...); err != nil {
 		return err
 	}
-	if err := migrate.Up(log, db, fsys, c.tableName); err != nil {
+	if err := migrate.Up(log, db, fsys, c.table(), c.options()...); err != nil {
 		return err
 	}
 	return nil
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+	"github.com/matthewmueller/migrate/internal/db"
+)
+
+type checkReversible struct {
+}
+
+func (in *checkReversible) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("check-reversible", "check that each down migration reverses its up migration")
+	return cmd
+}
+
+func (c *CLI) CheckReversible(ctx context.Context, in *checkReversible) (err error) {
+	if c.dbUrl == "" {
+		return errors.New("missing --db or $DATABASE_URL environment variable")
+	}
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	fsys, err := c.migrateFs()
+	if err != nil {
+		return err
+	}
+
+	// Run the migrations in a throwaway database
+	scratch, closeScratch, err := db.Scratch(c.dbUrl)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, closeScratch()) }()
+
+	unreversed, err := migrate.CheckReversible(log, scratch, fsys, c.table(), c.options()...)
+	for _, u := range unreversed {
+		log.Error(u.Migration.Name+" isn't reversible", "error", u.Err)
+		if u.Diff != "" {
+			fmt.Fprintln(c.Stdout, "-- restores the schema after "+u.Migration.Name+"\n"+u.Diff)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(unreversed) > 0 {
+		return fmt.Errorf("%d migrations aren't reversible", len(unreversed))
+	}
+	log.Info("all migrations are reversible")
+	return nil
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"context"
+	"os"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+)
+
+type seed struct {
+	Dir string
+}
+
+func (in *seed) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("seed", "run the seeds")
+	cmd.Flag("seeds", "seeds directory").String(&in.Dir).Default("")
+	return cmd
+}
+
+func (c *CLI) Seed(ctx context.Context, in *seed) error {
+	if in.Dir != "" {
+		c.seedsDir = in.Dir
+	}
+
+	// Connect to the database
+	db, err := c.dialDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	seedsDir, err := c.findSeedsDir()
+	if err != nil {
+		return err
+	}
+
+	return migrate.Seed(log, db, os.DirFS(seedsDir), c.table(), c.options()...)
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/migrate"
+	"github.com/matthewmueller/migrate/internal/db"
+	"github.com/matthewmueller/virt"
+)
+
+type squash struct {
+	Through int
+}
+
+func (in *squash) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("squash", "squash old migrations into a baseline")
+	cmd.Flag("through", "squash migrations up to and including this version").Int(&in.Through)
+	return cmd
+}
+
+func (c *CLI) Squash(ctx context.Context, in *squash) (err error) {
+	if c.dbUrl == "" {
+		return errors.New("missing --db or $DATABASE_URL environment variable")
+	}
+	if in.Through <= 0 {
+		return errors.New("--through must be greater than 0")
+	}
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	migrateDir, err := c.findMigrateDir()
+	if err != nil {
+		return err
+	}
+
+	// Build the baseline in a throwaway database
+	scratch, closeScratch, err := db.Scratch(c.dbUrl)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, closeScratch()) }()
+
+	return migrate.Squash(log, scratch, virt.OS(migrateDir), c.table(), uint(in.Through))
+}
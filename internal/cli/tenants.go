@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/livebud/cli"
+	"github.com/matthewmueller/logs"
+	"github.com/matthewmueller/migrate"
+)
+
+type tenants struct {
+	Tenants     []string
+	Pattern     string
+	Query       string
+	Concurrency int
+}
+
+func (in *tenants) Command(cmd cli.Command) cli.Command {
+	cmd = cmd.Command("tenants", "migrate up each tenant schema")
+	cmd.Flag("tenant", "tenant schema, repeatable").Strings(&in.Tenants).Default()
+	cmd.Flag("pattern", "migrate schemas matching a LIKE pattern (e.g. tenant_%)").String(&in.Pattern).Default("")
+	cmd.Flag("query", "migrate schemas returned by a SQL query").String(&in.Query).Default("")
+	cmd.Flag("concurrency", "number of tenants to migrate at once").Int(&in.Concurrency).Default(4)
+	return cmd
+}
+
+func (c *CLI) Tenants(ctx context.Context, in *tenants) error {
+	// Connect to the database
+	db, err := c.dialDb()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	fsys, err := c.migrateFs()
+	if err != nil {
+		return err
+	}
+
+	// Find the tenants, combining --tenant, --pattern and --query
+	schemas := in.Tenants
+	if in.Pattern != "" {
+		matches, err := migrate.Schemas(db, in.Pattern)
+		if err != nil {
+			return err
+		}
+		schemas = append(schemas, matches...)
+	}
+	if in.Query != "" {
+		matches, err := migrate.QuerySchemas(db, in.Query)
+		if err != nil {
+			return err
+		}
+		schemas = append(schemas, matches...)
+	}
+	schemas = unique(schemas)
+	if len(schemas) == 0 {
+		return errors.New("no tenants to migrate, use --tenant, --pattern or --query")
+	}
+
+	results := migrate.UpTenants(log, db, fsys, c.table(), schemas, in.Concurrency, c.options()...)
+	return summarize(log, "tenants", results)
+}
+
+// unique removes the duplicate schemas, keeping the first of each
+func unique(schemas []string) (deduped []string) {
+	seen := map[string]bool{}
+	for _, schema := range schemas {
+		if seen[schema] {
+			continue
+		}
+		seen[schema] = true
+		deduped = append(deduped, schema)
+	}
+	return deduped
+}
+
+// summarize logs the outcome of each target and returns an error if any of
+// them failed
+func summarize(log *logs.Logger, kind string, results []*migrate.Result) error {
+	for _, result := range results {
+		if result.Err != nil {
+			log.Error(result.Target, "error", result.Err)
+			continue
+		}
+		log.Info(result.Target, "version", fmt.Sprintf("%03d", result.Version))
+	}
+	if failed := migrate.Failed(results); len(failed) > 0 {
+		return fmt.Errorf("%d of %d %s failed to migrate", len(failed), len(results), kind)
+	}
+	return nil
+}
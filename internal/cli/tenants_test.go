@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestUnique(t *testing.T) {
+	is := is.New(t)
+	is.Equal(unique(nil), nil)
+	is.Equal(unique([]string{"tenant_a", "tenant_b", "tenant_a", "tenant_c", "tenant_b"}), []string{"tenant_a", "tenant_b", "tenant_c"})
+}
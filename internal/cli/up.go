@@ -8,16 +8,28 @@ import (
 )
 
 type up struct {
-	N *int
+	N         *int
+	Dump      bool
+	DbGlob    string
+	Workers   int
+	KeepGoing bool
 }
 
 func (in *up) Command(cmd cli.Command) cli.Command {
 	cmd = cmd.Command("up", "migrate up")
+	cmd.Flag("dump", "rewrite schema.sql after migrating").Bool(&in.Dump).Default(false)
+	cmd.Flag("db-glob", "migrate each sqlite file matching a pattern (e.g. data/*.db)").String(&in.DbGlob).Default("")
+	cmd.Flag("workers", "number of databases to migrate at once").Int(&in.Workers).Default(4)
+	cmd.Flag("keep-going", "keep migrating after a database fails").Bool(&in.KeepGoing).Default(false)
 	cmd.Arg("n", "go up by n").Optional().Int(&in.N)
 	return cmd
 }
 
 func (c *CLI) Up(ctx context.Context, in *up) error {
+	if in.DbGlob != "" {
+		return c.upGlob(in)
+	}
+
 	// Connect to the database
 	db, err := c.dialDb()
 	if err != nil {
@@ -38,10 +50,42 @@ func (c *CLI) Up(ctx context.Context, in *up) error {
 	// be a bit extra careful here
 	switch {
 	case in.N == nil:
-		return migrate.Up(log, db, fsys, c.tableName)
+		err = migrate.Up(log, db, fsys, c.table(), c.options()...)
 	case *in.N > 0:
-		return migrate.UpBy(log, db, fsys, c.tableName, *in.N)
+		err = migrate.UpBy(log, db, fsys, c.table(), *in.N, c.options()...)
+	}
+	if err != nil || !(in.Dump || c.dumpSchema) {
+		return err
 	}
 
+	// keep schema.sql in sync for code review
+	path, err := c.schemaFile("")
+	if err != nil {
+		return err
+	}
+	if err := c.dump(db, path); err != nil {
+		return err
+	}
+	log.Info("wrote: " + path)
 	return nil
 }
+
+// upGlob migrates many SQLite databases up at once
+func (c *CLI) upGlob(in *up) error {
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+
+	fsys, err := c.migrateFs()
+	if err != nil {
+		return err
+	}
+
+	pattern := resolveDir(c.Dir, in.DbGlob)
+	results, err := migrate.UpGlob(log, pattern, fsys, c.table(), in.Workers, in.KeepGoing, c.options()...)
+	if err != nil {
+		return err
+	}
+	return summarize(log, "databases", results)
+}
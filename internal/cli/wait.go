@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/matthewmueller/migrate/internal/db"
+)
+
+// waitForDb waits for the database to accept connections when --wait is
+// passed, so commands don't fail while the database is still starting up
+func (c *CLI) waitForDb(ctx context.Context) error {
+	if c.wait == "" || c.dbUrl == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(c.wait)
+	if err != nil {
+		return fmt.Errorf("invalid --wait %q: %w", c.wait, err)
+	}
+	log, err := c.log()
+	if err != nil {
+		return err
+	}
+	conn, err := db.Dial(c.dbUrl)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return db.Wait(ctx, log, conn, timeout)
+}
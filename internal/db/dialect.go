@@ -0,0 +1,16 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Dialect returns "sqlite" or "postgres" depending on the driver behind db
+func Dialect(db *sql.DB) string {
+	driver := strings.ToLower(fmt.Sprintf("%T", db.Driver()))
+	if strings.Contains(driver, "sqlite") {
+		return "sqlite"
+	}
+	return "postgres"
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/xo/dburl"
+)
+
+// Scratch creates a throwaway database alongside connString. SQLite databases
+// are created in a temporary file, Postgres databases are created on the same
+// server. Call close to drop the database when you're done.
+func Scratch(connString string) (db *sql.DB, close func() error, err error) {
+	u, err := dburl.Parse(connString)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return scratchPostgres(connString)
+	case "sqlite", "sqlite3":
+		return scratchSQLite()
+	default:
+		return nil, nil, fmt.Errorf("migrate doesn't support this url scheme: %s", u.Scheme)
+	}
+}
+
+func scratchSQLite() (*sql.DB, func() error, error) {
+	dir, err := os.MkdirTemp("", "migrate-")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := sql.Open("sqlite3", filepath.Join(dir, "scratch.db"))
+	if err != nil {
+		return nil, nil, errors.Join(err, os.RemoveAll(dir))
+	}
+	return db, func() error {
+		return errors.Join(db.Close(), os.RemoveAll(dir))
+	}, nil
+}
+
+func scratchPostgres(connString string) (*sql.DB, func() error, error) {
+	admin, err := Dial(connString)
+	if err != nil {
+		return nil, nil, err
+	}
+	name, err := scratchName()
+	if err != nil {
+		return nil, nil, errors.Join(err, admin.Close())
+	}
+	if _, err := admin.Exec(`CREATE DATABASE "` + name + `"`); err != nil {
+		return nil, nil, errors.Join(err, admin.Close())
+	}
+	drop := func() error {
+		_, err := admin.Exec(`DROP DATABASE IF EXISTS "` + name + `"`)
+		return errors.Join(err, admin.Close())
+	}
+	u, err := url.Parse(connString)
+	if err != nil {
+		return nil, nil, errors.Join(err, drop())
+	}
+	u.Path = "/" + name
+	db, err := Dial(u.String())
+	if err != nil {
+		return nil, nil, errors.Join(err, drop())
+	}
+	return db, func() error {
+		return errors.Join(db.Close(), drop())
+	}, nil
+}
+
+func scratchName() (string, error) {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "migrate_scratch_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// backoff between connection attempts
+const (
+	minBackoff = 250 * time.Millisecond
+	maxBackoff = 5 * time.Second
+)
+
+// Wait pings the database until it accepts connections or timeout passes,
+// doubling the time between attempts. This is useful when migrate starts
+// alongside the database, like in docker-compose or an init container.
+func Wait(ctx context.Context, log *slog.Logger, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	backoff := minBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			if attempt > 1 {
+				log.Info("database is ready", "after", time.Since(start).Round(time.Millisecond))
+			}
+			return nil
+		}
+		log.Info("waiting for the database", "attempt", attempt, "retry", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database wasn't reachable after %s: %w", timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
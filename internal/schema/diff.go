@@ -0,0 +1,238 @@
+package schema
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Diff returns the statements needed to go from one schema to another.
+// Destructive statements are flagged with a warning comment and changes that
+// can't be expressed as statements are left as TODO comments.
+func Diff(from, to *Schema) string {
+	d := &differ{from.Dialect, nil}
+
+	// drop the dependent objects that changed or went away first
+	d.dropObjects("TRIGGER", from.Triggers, to.Triggers)
+	d.dropObjects("VIEW", from.Views, to.Views)
+	d.dropObjects("INDEX", from.Indexes, to.Indexes)
+
+	// drop the foreign keys that changed or went away before the tables they
+	// reference
+	for _, table := range from.Tables {
+		d.dropForeignKeys(table, to.Table(table.Name))
+	}
+
+	// create and alter the tables
+	d.createObjects(from.Sequences, to.Sequences)
+	for _, table := range to.Tables {
+		if prev := from.Table(table.Name); prev != nil {
+			d.alterTable(prev, table)
+			continue
+		}
+		d.add(table.String())
+	}
+	for _, table := range from.Tables {
+		if to.Table(table.Name) == nil {
+			d.destructive("drops the "+table.Name+" table and all of its data", "DROP TABLE "+Quote(table.Name)+";")
+		}
+	}
+	d.dropObjects("SEQUENCE", from.Sequences, to.Sequences)
+
+	// add the foreign keys once every table they reference exists
+	for _, table := range to.Tables {
+		d.addForeignKeys(from.Table(table.Name), table)
+	}
+
+	// recreate the functions, then the objects that may depend on them
+	d.dropObjects("FUNCTION", from.Functions, to.Functions)
+	d.createObjects(from.Functions, to.Functions)
+	d.createObjects(from.Indexes, to.Indexes)
+	d.createObjects(from.Views, to.Views)
+	d.createObjects(from.Triggers, to.Triggers)
+
+	return strings.Join(d.statements, "\n\n")
+}
+
+type differ struct {
+	dialect    string
+	statements []string
+}
+
+func (d *differ) add(statement string) {
+	d.statements = append(d.statements, statement)
+}
+
+func (d *differ) destructive(reason, statement string) {
+	d.add("-- WARNING: destructive, " + reason + "\n" + statement)
+}
+
+func (d *differ) todo(reason string) {
+	d.add("-- TODO: " + reason)
+}
+
+func (d *differ) alterTable(from, to *Table) {
+	alter := "ALTER TABLE " + Quote(to.Name) + " "
+	for _, column := range to.Columns {
+		prev := from.Column(column.Name)
+		if prev == nil {
+			d.add(alter + "ADD COLUMN " + column.String() + ";")
+			continue
+		}
+		d.alterColumn(to, prev, column)
+	}
+	for _, column := range from.Columns {
+		if to.Column(column.Name) == nil {
+			d.destructive("drops the "+to.Name+"."+column.Name+" column and all of its data",
+				alter+"DROP COLUMN "+Quote(column.Name)+";")
+		}
+	}
+	if d.dialect == "sqlite" {
+		if sameColumns(from, to) && normalize(from.SQL) != normalize(to.SQL) {
+			d.todo("SQLite can't alter the constraints of " + to.Name + ", rebuild the table by hand:\n-- " +
+				strings.ReplaceAll(to.String(), "\n", "\n-- "))
+		}
+		return
+	}
+	fromConstraints := constraints(from.Constraints)
+	toConstraints := constraints(to.Constraints)
+	for _, constraint := range from.Constraints {
+		if def, ok := toConstraints[constraint.Name]; !constraint.ForeignKey() && (!ok || def != constraint.Definition) {
+			d.add(alter + "DROP CONSTRAINT " + Quote(constraint.Name) + ";")
+		}
+	}
+	for _, constraint := range to.Constraints {
+		if def, ok := fromConstraints[constraint.Name]; !constraint.ForeignKey() && (!ok || def != constraint.Definition) {
+			d.add(to.AddConstraint(constraint))
+		}
+	}
+}
+
+// dropForeignKeys drops the foreign keys of from that aren't in to. The table
+// may be going away, in which case to is nil.
+func (d *differ) dropForeignKeys(from, to *Table) {
+	next := map[string]string{}
+	if to != nil {
+		next = constraints(to.ForeignKeys())
+	}
+	for _, constraint := range from.ForeignKeys() {
+		if def, ok := next[constraint.Name]; !ok || def != constraint.Definition {
+			d.add("ALTER TABLE " + Quote(from.Name) + " DROP CONSTRAINT " + Quote(constraint.Name) + ";")
+		}
+	}
+}
+
+// addForeignKeys adds the foreign keys of to that aren't in from. The table
+// may be new, in which case from is nil.
+func (d *differ) addForeignKeys(from, to *Table) {
+	prev := map[string]string{}
+	if from != nil {
+		prev = constraints(from.ForeignKeys())
+	}
+	for _, constraint := range to.ForeignKeys() {
+		if def, ok := prev[constraint.Name]; !ok || def != constraint.Definition {
+			d.add(to.AddConstraint(constraint))
+		}
+	}
+}
+
+func (d *differ) alterColumn(table *Table, from, to *Column) {
+	if *from == *to {
+		return
+	}
+	if d.dialect == "sqlite" {
+		d.todo("SQLite can't alter the " + table.Name + "." + to.Name + " column to " + to.String() + ", rebuild the table by hand")
+		return
+	}
+	alter := "ALTER TABLE " + Quote(table.Name) + " ALTER COLUMN " + Quote(to.Name) + " "
+	if from.Type != to.Type {
+		d.destructive("changing the type from "+from.Type+" to "+to.Type+" may lose data",
+			alter+"TYPE "+to.Type+" USING "+Quote(to.Name)+"::"+to.Type+";")
+	}
+	if from.Default != to.Default {
+		if to.Default == "" {
+			d.add(alter + "DROP DEFAULT;")
+		} else {
+			d.add(alter + "SET DEFAULT " + to.Default + ";")
+		}
+	}
+	if from.Identity != to.Identity {
+		d.todo("change the identity of " + table.Name + "." + to.Name + " to " + to.String())
+	}
+	if from.NotNull != to.NotNull {
+		if to.NotNull {
+			d.add(alter + "SET NOT NULL;")
+		} else {
+			d.add(alter + "DROP NOT NULL;")
+		}
+	}
+}
+
+// dropObjects drops the objects that were removed or changed
+func (d *differ) dropObjects(kind string, from, to []*Object) {
+	next := objects(to)
+	for _, object := range from {
+		if sql, ok := next[object.Name]; ok && sql == object.SQL {
+			continue
+		}
+		statement := "DROP " + kind + " "
+		switch {
+		case kind == "FUNCTION":
+			// function names include their signature
+			statement += object.Name
+		case kind == "TRIGGER" && d.dialect == "postgres":
+			statement += Quote(object.Name) + " ON " + Quote(object.Table)
+		default:
+			statement += Quote(object.Name)
+		}
+		d.add(statement + ";")
+	}
+}
+
+// createObjects creates the objects that were added or changed
+func (d *differ) createObjects(from, to []*Object) {
+	prev := objects(from)
+	for _, object := range to {
+		if sql, ok := prev[object.Name]; ok && sql == object.SQL {
+			continue
+		}
+		d.add(statement(object.SQL))
+	}
+}
+
+var reSpace = regexp.MustCompile(`\s+`)
+var reSpaceAround = regexp.MustCompile(`\s*([(),;])\s*`)
+
+// normalize SQL so that quoting, casing and spacing differences don't matter
+func normalize(sql string) string {
+	sql = strings.ToLower(strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "").Replace(sql))
+	sql = reSpace.ReplaceAllString(strings.TrimSpace(sql), " ")
+	return reSpaceAround.ReplaceAllString(sql, "$1")
+}
+
+func sameColumns(from, to *Table) bool {
+	if len(from.Columns) != len(to.Columns) {
+		return false
+	}
+	for i := range from.Columns {
+		if *from.Columns[i] != *to.Columns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func constraints(list []*Constraint) map[string]string {
+	m := make(map[string]string, len(list))
+	for _, constraint := range list {
+		m[constraint.Name] = constraint.Definition
+	}
+	return m
+}
+
+func objects(list []*Object) map[string]string {
+	m := make(map[string]string, len(list))
+	for _, object := range list {
+		m[object.Name] = object.SQL
+	}
+	return m
+}
@@ -0,0 +1,202 @@
+package schema
+
+import (
+	"database/sql"
+	"strings"
+)
+
+func inspectPostgres(db *sql.DB) (*Schema, error) {
+	schema := &Schema{Dialect: "postgres"}
+	tables := map[string]*Table{}
+
+	// sequences that aren't backing an identity or serial column. Dropping the
+	// column drops these sequences too.
+	sequences, err := postgresObjects(db, `
+		SELECT s.sequencename, '', 'CREATE SEQUENCE ' || quote_ident(s.sequencename) || ' AS ' || s.data_type ||
+			' INCREMENT BY ' || s.increment_by || ' MINVALUE ' || s.min_value || ' MAXVALUE ' || s.max_value ||
+			' START WITH ' || s.start_value || CASE WHEN s.cycle THEN ' CYCLE' ELSE ' NO CYCLE' END
+		FROM pg_sequences s
+		JOIN pg_namespace n ON n.nspname = s.schemaname
+		JOIN pg_class c ON c.relname = s.sequencename AND c.relnamespace = n.oid
+		WHERE s.schemaname = current_schema() AND NOT EXISTS (
+			SELECT 1 FROM pg_depend d WHERE d.objid = c.oid AND d.deptype IN ('i', 'a')
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	schema.Sequences = sequences
+
+	// tables and columns
+	rows, err := db.Query(`
+		SELECT c.relname, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			coalesce(pg_get_expr(d.adbin, d.adrelid), ''), a.attidentity::text
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+		LEFT JOIN pg_attrdef d ON d.adrelid = c.oid AND d.adnum = a.attnum
+		WHERE n.nspname = current_schema() AND c.relkind IN ('r', 'p')
+		ORDER BY c.relname, a.attnum
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		column := new(Column)
+		if err := rows.Scan(&name, &column.Name, &column.Type, &column.NotNull, &column.Default, &column.Identity); err != nil {
+			return nil, err
+		}
+		table, ok := tables[name]
+		if !ok {
+			table = &Table{Name: name}
+			tables[name] = table
+			schema.Tables = append(schema.Tables, table)
+		}
+		table.Columns = append(table.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := postgresSerials(db, tables); err != nil {
+		return nil, err
+	}
+
+	// constraints
+	rows, err = db.Query(`
+		SELECT c.relname, con.conname, pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND con.contype IN ('p', 'u', 'f', 'c', 'x')
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		constraint := new(Constraint)
+		if err := rows.Scan(&name, &constraint.Name, &constraint.Definition); err != nil {
+			return nil, err
+		}
+		if table, ok := tables[name]; ok {
+			table.Constraints = append(table.Constraints, constraint)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// indexes that aren't backing a constraint
+	schema.Indexes, err = postgresObjects(db, `
+		SELECT i.indexname, i.tablename, i.indexdef
+		FROM pg_indexes i
+		WHERE i.schemaname = current_schema() AND NOT EXISTS (
+			SELECT 1 FROM pg_constraint con
+			JOIN pg_namespace n ON n.oid = con.connamespace
+			WHERE n.nspname = i.schemaname AND con.conname = i.indexname
+		)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// views
+	schema.Views, err = postgresObjects(db, `
+		SELECT viewname, viewname, 'CREATE VIEW ' || quote_ident(viewname) || ' AS' || chr(10) || definition
+		FROM pg_views
+		WHERE schemaname = current_schema()
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// functions
+	schema.Functions, err = postgresObjects(db, `
+		SELECT p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')', '', pg_get_functiondef(p.oid)
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname = current_schema() AND p.prokind IN ('f', 'p')
+		AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = p.oid AND d.deptype = 'e')
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	// triggers
+	schema.Triggers, err = postgresObjects(db, `
+		SELECT t.tgname, c.relname, pg_get_triggerdef(t.oid)
+		FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = current_schema() AND NOT t.tgisinternal
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func postgresObjects(db *sql.DB, query string) (objects []*Object, err error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		object := new(Object)
+		if err := rows.Scan(&object.Name, &object.Table, &object.SQL); err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, rows.Err()
+}
+
+// serialTypes are the serial types by the type of their column
+var serialTypes = map[string]string{
+	"smallint": "smallserial",
+	"integer":  "serial",
+	"bigint":   "bigserial",
+}
+
+// postgresSerials turns the columns that use a sequence they own into serial
+// columns, since the sequence is created and dropped along with the column
+func postgresSerials(db *sql.DB, tables map[string]*Table) error {
+	rows, err := db.Query(`
+		SELECT t.relname, a.attname, s.relname
+		FROM pg_depend d
+		JOIN pg_class s ON s.oid = d.objid AND s.relkind = 'S'
+		JOIN pg_class t ON t.oid = d.refobjid
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+		JOIN pg_namespace n ON n.oid = s.relnamespace
+		WHERE n.nspname = current_schema() AND d.deptype = 'a'
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName, columnName, sequence string
+		if err := rows.Scan(&tableName, &columnName, &sequence); err != nil {
+			return err
+		}
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+		column := table.Column(columnName)
+		if column == nil || !strings.HasPrefix(column.Default, "nextval(") || !strings.Contains(column.Default, sequence) {
+			continue
+		}
+		serial, ok := serialTypes[column.Type]
+		if !ok {
+			continue
+		}
+		column.Type, column.Default = serial, ""
+	}
+	return rows.Err()
+}
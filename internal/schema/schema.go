@@ -0,0 +1,236 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/matthewmueller/migrate/internal/db"
+)
+
+// Schema is a snapshot of the database structure
+type Schema struct {
+	Dialect   string
+	Sequences []*Object
+	Tables    []*Table
+	Indexes   []*Object
+	Views     []*Object
+	Functions []*Object
+	Triggers  []*Object
+}
+
+// Table in the schema
+type Table struct {
+	Name        string
+	Columns     []*Column
+	Constraints []*Constraint
+	// SQL is the original definition when the database keeps one (SQLite)
+	SQL string
+}
+
+// Column in a table
+type Column struct {
+	Name     string
+	Type     string
+	NotNull  bool
+	Default  string
+	Identity string
+}
+
+// Constraint on a table
+type Constraint struct {
+	Name       string
+	Definition string
+}
+
+// Object is any other named object that's defined by a single statement
+type Object struct {
+	Name  string
+	Table string
+	SQL   string
+}
+
+// Inspect the database, skipping the excluded tables
+func Inspect(conn *sql.DB, exclude ...string) (*Schema, error) {
+	var schema *Schema
+	var err error
+	switch dialect := db.Dialect(conn); dialect {
+	case "sqlite":
+		schema, err = inspectSQLite(conn)
+	case "postgres":
+		schema, err = inspectPostgres(conn)
+	default:
+		return nil, fmt.Errorf("schema: unsupported dialect %q", dialect)
+	}
+	if err != nil {
+		return nil, err
+	}
+	schema.exclude(exclude...)
+	schema.sort()
+	return schema, nil
+}
+
+// Table finds a table by name
+func (s *Schema) Table(name string) *Table {
+	for _, table := range s.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// Column finds a column by name
+func (t *Table) Column(name string) *Column {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+func (s *Schema) exclude(names ...string) {
+	skip := map[string]bool{}
+	for _, name := range names {
+		skip[name] = true
+	}
+	tables := s.Tables[:0]
+	for _, table := range s.Tables {
+		if !skip[table.Name] {
+			tables = append(tables, table)
+		}
+	}
+	s.Tables = tables
+	s.Indexes = excludeObjects(s.Indexes, skip)
+	s.Triggers = excludeObjects(s.Triggers, skip)
+}
+
+func excludeObjects(objects []*Object, skip map[string]bool) []*Object {
+	results := objects[:0]
+	for _, object := range objects {
+		if !skip[object.Table] {
+			results = append(results, object)
+		}
+	}
+	return results
+}
+
+func (s *Schema) sort() {
+	sort.Slice(s.Tables, func(i, j int) bool { return s.Tables[i].Name < s.Tables[j].Name })
+	for _, table := range s.Tables {
+		sort.Slice(table.Constraints, func(i, j int) bool {
+			return table.Constraints[i].Name < table.Constraints[j].Name
+		})
+	}
+	sortObjects(s.Sequences)
+	sortObjects(s.Indexes)
+	sortObjects(s.Views)
+	sortObjects(s.Functions)
+	sortObjects(s.Triggers)
+}
+
+func sortObjects(objects []*Object) {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+}
+
+// String returns the canonical SQL for the schema
+func (s *Schema) String() string {
+	var statements []string
+	for _, sequence := range s.Sequences {
+		statements = append(statements, statement(sequence.SQL))
+	}
+	for _, table := range s.Tables {
+		statements = append(statements, table.String())
+	}
+	// foreign keys come after the tables, so tables can reference each other
+	// regardless of their order
+	for _, table := range s.Tables {
+		for _, constraint := range table.ForeignKeys() {
+			statements = append(statements, table.AddConstraint(constraint))
+		}
+	}
+	for _, objects := range [][]*Object{s.Functions, s.Indexes, s.Views, s.Triggers} {
+		for _, object := range objects {
+			statements = append(statements, statement(object.SQL))
+		}
+	}
+	return strings.Join(statements, "\n\n")
+}
+
+// String returns the CREATE TABLE statement. Foreign keys are left out, see
+// ForeignKeys.
+func (t *Table) String() string {
+	if t.SQL != "" {
+		return statement(t.SQL)
+	}
+	lines := make([]string, 0, len(t.Columns)+len(t.Constraints))
+	for _, column := range t.Columns {
+		lines = append(lines, "  "+column.String())
+	}
+	for _, constraint := range t.Constraints {
+		if constraint.ForeignKey() {
+			continue
+		}
+		lines = append(lines, "  CONSTRAINT "+Quote(constraint.Name)+" "+constraint.Definition)
+	}
+	return "CREATE TABLE " + Quote(t.Name) + " (\n" + strings.Join(lines, ",\n") + "\n);"
+}
+
+// ForeignKeys returns the foreign keys that need to be added after the table
+// is created. Tables with their original definition (SQLite) keep their
+// foreign keys inline.
+func (t *Table) ForeignKeys() (foreignKeys []*Constraint) {
+	if t.SQL != "" {
+		return nil
+	}
+	for _, constraint := range t.Constraints {
+		if constraint.ForeignKey() {
+			foreignKeys = append(foreignKeys, constraint)
+		}
+	}
+	return foreignKeys
+}
+
+// AddConstraint returns the ALTER TABLE statement that adds the constraint
+func (t *Table) AddConstraint(constraint *Constraint) string {
+	return "ALTER TABLE " + Quote(t.Name) + " ADD CONSTRAINT " + Quote(constraint.Name) + " " + constraint.Definition + ";"
+}
+
+// ForeignKey returns true if the constraint references another table
+func (c *Constraint) ForeignKey() bool {
+	return strings.HasPrefix(c.Definition, "FOREIGN KEY")
+}
+
+// String returns the column definition
+func (c *Column) String() string {
+	def := Quote(c.Name) + " " + c.Type
+	if c.Default != "" {
+		def += " DEFAULT " + c.Default
+	}
+	switch c.Identity {
+	case "a":
+		def += " GENERATED ALWAYS AS IDENTITY"
+	case "d":
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+	if c.NotNull {
+		def += " NOT NULL"
+	}
+	return def
+}
+
+// Quote an identifier
+func Quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// statement trims the SQL and ensures it ends with a semicolon
+func statement(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if !strings.HasSuffix(sql, ";") {
+		sql += ";"
+	}
+	return sql
+}
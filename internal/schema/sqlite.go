@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"database/sql"
+)
+
+func inspectSQLite(db *sql.DB) (*Schema, error) {
+	schema := &Schema{Dialect: "sqlite"}
+	rows, err := db.Query(`
+		SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var kind, name, table, sql string
+		if err := rows.Scan(&kind, &name, &table, &sql); err != nil {
+			return nil, err
+		}
+		switch kind {
+		case "table":
+			schema.Tables = append(schema.Tables, &Table{Name: name, SQL: sql})
+		case "index":
+			schema.Indexes = append(schema.Indexes, &Object{Name: name, Table: table, SQL: sql})
+		case "view":
+			schema.Views = append(schema.Views, &Object{Name: name, Table: table, SQL: sql})
+		case "trigger":
+			schema.Triggers = append(schema.Triggers, &Object{Name: name, Table: table, SQL: sql})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, table := range schema.Tables {
+		columns, err := sqliteColumns(db, table.Name)
+		if err != nil {
+			return nil, err
+		}
+		table.Columns = columns
+	}
+	return schema, nil
+}
+
+func sqliteColumns(db *sql.DB, table string) (columns []*Column, err error) {
+	rows, err := db.Query(`SELECT name, type, "notnull", dflt_value FROM pragma_table_info(?) ORDER BY cid`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		column := new(Column)
+		var def sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &column.NotNull, &def); err != nil {
+			return nil, err
+		}
+		column.Default = def.String
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,131 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Set is the migrations in a directory, validated and paired by version
+type Set struct {
+	// Pairs are the versioned migrations, sorted by version
+	Pairs []*Pair
+	// Repeatables are re-applied whenever they change, sorted by name
+	Repeatables []*Migration
+	// files are all the files that were read, including the ones that aren't
+	// migrations
+	files map[string]string
+}
+
+// Pair is the up and down migration of a version
+type Pair struct {
+	Version uint
+	Name    string // e.g. create_users
+	Up      *Migration
+	Down    *Migration // nil when there's no down migration
+}
+
+// Irreversible returns true if the pair doesn't have a down migration or is
+// marked with "-- migrate:irreversible"
+func (p *Pair) Irreversible() bool {
+	return p.Down == nil || reIrreversible.MatchString(p.Up.Code) || reIrreversible.MatchString(p.Down.Code)
+}
+
+// Squashed returns true if the pair is a baseline created by Squash
+func (p *Pair) Squashed() bool {
+	return reSquashed.MatchString(p.Up.Code)
+}
+
+// Load reads the migrations in fsys. Every version needs an up migration and
+// only one of each, and the up and down migrations of a version need to have
+// the same name.
+func Load(fsys fs.FS) (*Set, error) {
+	files, err := getFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+	sources, isSources := fsys.(*sourcesFS)
+	if !isSources {
+		if files, err = unnest(files); err != nil {
+			return nil, err
+		}
+	}
+	ups, err := toMigrations(files, DirectionUp)
+	if err != nil {
+		return nil, err
+	}
+	downs, err := toMigrations(files, DirectionDown)
+	if err != nil {
+		return nil, err
+	}
+	set := &Set{
+		Repeatables: repeatableMigrations(files),
+		files:       files,
+	}
+	if isSources {
+		sources.tag(ups)
+		sources.tag(downs)
+		sources.tag(set.Repeatables)
+	}
+	for i, up := range ups {
+		if i > 0 && ups[i-1].Version == up.Version {
+			return nil, fmt.Errorf("%s and %s have the same version, run migrate renumber to fix", ups[i-1].Name, up.Name)
+		}
+		set.Pairs = append(set.Pairs, &Pair{
+			Version: up.Version,
+			Name:    migrationName(up),
+			Up:      up,
+		})
+	}
+	for i, down := range downs {
+		if i > 0 && downs[i-1].Version == down.Version {
+			return nil, fmt.Errorf("%s and %s have the same version, run migrate renumber to fix", downs[i-1].Name, down.Name)
+		}
+		pair := set.Find(down.Version)
+		if pair == nil {
+			return nil, fmt.Errorf("%s has no up migration", down.Name)
+		} else if name := migrationName(down); name != pair.Name {
+			return nil, fmt.Errorf("%s and %s have different names", pair.Up.Name, down.Name)
+		}
+		pair.Down = down
+	}
+	return set, nil
+}
+
+// migrationName returns the name between the version and the direction
+// (e.g. create_users in 001_create_users.up.sql)
+func migrationName(migration *Migration) string {
+	_, rest, _ := strings.Cut(migration.Name, "_")
+	name, _, _ := strings.Cut(rest, "."+string(migration.Dir)+".")
+	return name
+}
+
+// Find the pair with version
+func (s *Set) Find(version uint) *Pair {
+	for _, pair := range s.Pairs {
+		if pair.Version == version {
+			return pair
+		}
+	}
+	return nil
+}
+
+// Ups returns the up migrations, sorted by version
+func (s *Set) Ups() []*Migration {
+	ups := make([]*Migration, len(s.Pairs))
+	for i, pair := range s.Pairs {
+		ups[i] = pair.Up
+	}
+	return ups
+}
+
+// Downs returns the down migrations, sorted by version. Versions without a
+// down migration are left out.
+func (s *Set) Downs() (downs []*Migration) {
+	for _, pair := range s.Pairs {
+		if pair.Down != nil {
+			downs = append(downs, pair.Down)
+		}
+	}
+	return downs
+}
@@ -16,6 +16,7 @@ import (
 
 	"github.com/jackc/pgconn"
 	"github.com/matthewmueller/logs"
+	database "github.com/matthewmueller/migrate/internal/db"
 	"github.com/matthewmueller/migrate/internal/dedent"
 	"github.com/matthewmueller/text"
 	"github.com/matthewmueller/virt"
@@ -35,22 +36,44 @@ var ErrNoMigrations = errors.New("no migrations")
 // ErrNotEnoughMigrations happens when your migrations folder has less migrations than remote's version
 var ErrNotEnoughMigrations = errors.New("remote migration version greater than the number of migrations you have")
 
+// ErrIrreversible happens when a down migration is missing or marked with
+// "-- migrate:irreversible"
+var ErrIrreversible = errors.New("irreversible migration")
+
+// reIrreversible matches the irreversible directive within a migration
+var reIrreversible = regexp.MustCompile(`(?m)^\s*--\s*migrate:irreversible\b`)
+
 // File is a writable file
 type File interface {
 	fs.FS
 	io.Writer
 }
 
-// New creates a new migrations in dir
-func New(log *slog.Logger, fsys virt.FS, name string) error {
-	log = logger(log)
+// New creates a new migration in fsys and returns the paths of the up and
+// down files. Conventional names are prefilled with SQL for the dialect set
+// by WithDialect (e.g. create_users, add_email_to_users, drop_orders and
+// add_index_on_users_email). Projects can override the SQL with templates in
+// templates/<kind>.up.sql and templates/<kind>.down.sql, where kind is
+// create, add_column, drop, add_index or default.
+func New(log *slog.Logger, fsys virt.FS, name string, options ...Option) (paths []string, err error) {
+	config := newConfig(logger(log), options)
+	up, down, err := newScaffold(text.Snake(name), config.dialect).render(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return create(config.log, fsys, name, up, down)
+}
+
+// create the up and down migration files, numbered after the latest migration
+func create(log *slog.Logger, fsys virt.FS, name, upCode, downCode string) (paths []string, err error) {
+	// files are written to the root, so don't look for nested migrations
 	files, err := getFiles(fsys)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	migrations, err := upMigrations(files)
+	migrations, err := toMigrations(files, DirectionUp)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var latest uint
 	if len(migrations) > 0 {
@@ -59,18 +82,18 @@ func New(log *slog.Logger, fsys virt.FS, name string) error {
 	filename := pad(latest+1) + "_" + text.Snake(name)
 
 	// up file
-	if err := fsys.WriteFile(filename+".up.sql", []byte{}, 0644); err != nil {
-		return err
+	if err := fsys.WriteFile(filename+".up.sql", []byte(upCode), 0644); err != nil {
+		return nil, err
 	}
 	log.Info("wrote: " + filename + ".up.sql")
 
 	// down file
-	if err := fsys.WriteFile(filename+".down.sql", []byte{}, 0644); err != nil {
-		return err
+	if err := fsys.WriteFile(filename+".down.sql", []byte(downCode), 0644); err != nil {
+		return nil, err
 	}
 	log.Info("wrote: " + filename + ".down.sql")
 
-	return nil
+	return []string{filename + ".up.sql", filename + ".down.sql"}, nil
 }
 
 // Migration struct
@@ -79,238 +102,294 @@ type Migration struct {
 	Code    string
 	Dir     Direction
 	Version uint
+	Source  string // name of the source, when using Sources
 }
 
 // LocalVersion fetches the latest local version
 func LocalVersion(fsys fs.FS) (name string, err error) {
-	files, err := getFiles(fsys)
-	if err != nil {
-		return name, err
-	}
-	migrations, err := upMigrations(files)
+	set, err := Load(fsys)
 	if err != nil {
 		return name, err
-	} else if len(migrations) == 0 {
-		return name, ErrNoMigrations
+	} else if len(set.Pairs) == 0 {
+		return name, noMigrations(set.files)
 	}
-	migration := migrations[len(migrations)-1]
-	return migration.Name, nil
+	return set.Pairs[len(set.Pairs)-1].Up.Name, nil
 }
 
 // RemoteVersion fetches the latest local version
-func RemoteVersion(db *sql.DB, fsys fs.FS, tableName string) (name string, err error) {
+func RemoteVersion(db *sql.DB, fsys fs.FS, tableName string, options ...Option) (name string, err error) {
+	config := newConfig(nil, options)
 	if err := ensureTableExists(db, tableName); err != nil {
 		return name, err
 	}
-	remote, err := getRemoteVersion(db, tableName)
+	remote, err := getRemoteVersion(db, tableName, config.namespace)
 	if err != nil {
 		return name, err
 	} else if remote == 0 {
 		return name, ErrNoMigrations
 	}
-	files, err := getFiles(fsys)
-	if err != nil {
-		return name, err
-	}
-	migrations, err := upMigrations(files)
+	set, err := Load(fsys)
 	if err != nil {
 		return name, err
-	} else if len(migrations) == 0 {
+	} else if len(set.Pairs) == 0 {
 		return name, ErrNoMigrations
 	}
-	migration := migrations[remote-1]
-	return migration.Name, nil
+	pair := set.Find(remote)
+	if pair == nil {
+		return name, ErrNotEnoughMigrations
+	}
+	return pair.Up.Name, nil
 }
 
 // Up migrates the database up to the latest migration
-func Up(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string) error {
+func Up(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, options ...Option) error {
 	log = logger(log)
-	return UpBy(log, db, fsys, tableName, math.MaxInt32)
+	return UpBy(log, db, fsys, tableName, math.MaxInt32, options...)
 }
 
 // UpBy migrations the database up by i
-func UpBy(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, i int) error {
+func UpBy(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, i int, options ...Option) error {
 	log = logger(log)
-	files, err := getFiles(fsys)
-	if err != nil {
+	config := newConfig(log, options)
+	if err := config.validate(db); err != nil {
 		return err
 	}
-	if len(files) == 0 {
-		return ErrNoMigrations
-	}
-	migrations, err := upMigrations(files)
+	set, err := Load(fsys)
 	if err != nil {
 		return err
 	}
-	if len(migrations) == 0 {
-		return ErrNoMigrations
+	if len(set.Pairs) == 0 {
+		return noMigrations(set.files)
 	}
+	migrations := set.Ups()
 	if err := ensureTableExists(db, tableName); err != nil {
 		return err
 	}
-	remote, err := getRemoteVersion(db, tableName)
-	if err != nil {
+	if err := reconcileSquash(db, tableName, config.namespace, migrations); err != nil {
+		return err
+	}
+	if err := checkDirty(db, tableName, config.namespace); err != nil {
 		return err
 	}
-	local := migrations[len(migrations)-1].Version
-	tx, err := db.Begin()
+	remote, err := getRemoteVersion(db, tableName, config.namespace)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	// next remote
-	remote++
-	for i > 0 && remote <= local {
-		migration := migrations[remote-1]
 
-		// execute the migration code
-		if _, err := tx.Exec(migration.Code); err != nil {
-			return format(migration, err)
+	// find the pending migrations
+	var pending []*Migration
+	remaining := 0
+	for _, migration := range migrations {
+		if migration.Version <= remote {
+			continue
+		} else if len(pending) < i {
+			pending = append(pending, migration)
+		} else {
+			remaining++
 		}
+	}
 
-		// increment version
-		if err := insertVersion(tx, tableName, remote); err != nil {
+	// re-apply the repeatable migrations that changed once the versioned
+	// migrations are all applied
+	var repeatables []*Migration
+	if remaining == 0 {
+		repeatables, err = changedRepeatables(db, tableName, config.namespace, set.Repeatables)
+		if err != nil {
 			return err
 		}
+	}
+	if len(pending) == 0 && len(repeatables) == 0 {
+		return nil
+	}
 
-		// log the next migration
-		log.Info(migration.Name)
+	apply := func(tx *sql.Tx) error {
+		for _, migration := range pending {
+			// execute the migration code
+			if err := config.exec(tx, migration); err != nil {
+				return err
+			}
 
-		// update counts
-		i--
-		remote++
+			// increment version
+			if err := insertVersion(tx, tableName, config.namespace, migration.Version); err != nil {
+				return err
+			}
+			if err := insertHistory(tx, tableName, config.namespace, migration, string(DirectionUp)); err != nil {
+				return err
+			}
+		}
+		for _, migration := range repeatables {
+			if err := config.exec(tx, migration); err != nil {
+				return err
+			}
+			if err := upsertChecksum(tx, repeatableTable(tableName), config.namespace, migration); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
 
-	return tx.Commit()
+	return config.run(append(pending, repeatables...), func() error {
+		if len(pending) == 0 {
+			return transaction(db, apply)
+		}
+		return transact(db, tableName, config.namespace, versions(pending), apply)
+	})
 }
 
 // Down migrates the database down to 0
-func Down(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string) error {
+func Down(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, options ...Option) error {
 	log = logger(log)
-	return DownBy(log, db, fsys, tableName, math.MaxInt32)
+	return DownBy(log, db, fsys, tableName, math.MaxInt32, options...)
 }
 
 // DownBy migrations the database down by i
-func DownBy(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, i int) error {
+func DownBy(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, i int, options ...Option) error {
 	log = logger(log)
-	files, err := getFiles(fsys)
-	if err != nil {
+	config := newConfig(log, options)
+	if err := config.validate(db); err != nil {
 		return err
 	}
-	if len(files) == 0 {
-		return ErrNoMigrations
-	}
-	migrations, err := downMigrations(files)
+	set, err := Load(fsys)
 	if err != nil {
 		return err
 	}
-	if len(migrations) == 0 {
-		return ErrNoMigrations
+	ups := set.Ups()
+	if len(ups) == 0 {
+		return noMigrations(set.files)
 	}
 	if err := ensureTableExists(db, tableName); err != nil {
 		return err
 	}
-	// get the current remote
-	remote, err := getRemoteVersion(db, tableName)
-	if err != nil {
+	if err := reconcileSquash(db, tableName, config.namespace, ups); err != nil {
 		return err
 	}
-	// get the earliest local
-	local := migrations[0].Version
-
-	tx, err := db.Begin()
+	if err := checkDirty(db, tableName, config.namespace); err != nil {
+		return err
+	}
+	// get the applied versions, latest first
+	applied, err := getAppliedVersions(db, tableName, config.namespace)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-	// next remote
-	for i > 0 && remote >= local {
-		if len(migrations) < int(remote) {
-			return ErrNotEnoughMigrations
-		}
-		migration := migrations[remote-1]
+	if len(applied) > i {
+		applied = applied[:i]
+	}
 
-		// execute the migration code
-		if _, err := tx.Exec(migration.Code); err != nil {
-			return format(migration, err)
+	// match each applied version with its down migration before running
+	// anything, so we don't leave the database half-migrated
+	migrations := make([]*Migration, len(applied))
+	for j, version := range applied {
+		migration, err := set.reverse(version)
+		if err != nil {
+			return err
 		}
+		migrations[j] = migration
+	}
 
-		// decrement version
-		if err := deleteVersion(tx, tableName, remote); err != nil {
+	if len(migrations) == 0 {
+		return nil
+	}
+	if len(set.Repeatables) > 0 {
+		if err := ensureChecksumTableExists(db, repeatableTable(tableName)); err != nil {
 			return err
 		}
-
-		// log the next migration
-		log.Info(migration.Name)
-
-		// update counts
-		i--
-		remote--
 	}
 
-	return tx.Commit()
+	return config.run(migrations, func() error {
+		return transact(db, tableName, config.namespace, versions(migrations), func(tx *sql.Tx) error {
+			for _, migration := range migrations {
+				// execute the migration code
+				if err := config.exec(tx, migration); err != nil {
+					return err
+				}
+
+				// decrement version
+				if err := deleteVersion(tx, tableName, config.namespace, migration.Version); err != nil {
+					return err
+				}
+				if err := insertHistory(tx, tableName, config.namespace, migration, string(DirectionDown)); err != nil {
+					return err
+				}
+			}
+			if len(set.Repeatables) == 0 {
+				return nil
+			}
+			return clearRepeatables(tx, tableName, config.namespace)
+		})
+	})
 }
 
-func Redo(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string) error {
+// Redo runs the latest down migration followed by its up migration
+func Redo(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, options ...Option) error {
 	log = logger(log)
-	files, err := getFiles(fsys)
+	config := newConfig(log, options)
+	if err := config.validate(db); err != nil {
+		return err
+	}
+	set, err := Load(fsys)
 	if err != nil {
 		return err
 	}
-	if len(files) == 0 {
-		return ErrNoMigrations
+	upMigrations := set.Ups()
+	if len(upMigrations) == 0 {
+		return noMigrations(set.files)
 	}
 
 	if err := ensureTableExists(db, tableName); err != nil {
 		return err
 	}
-
-	// get the current remote
-	remote, err := getRemoteVersion(db, tableName)
-	if err != nil {
+	if err := reconcileSquash(db, tableName, config.namespace, upMigrations); err != nil {
 		return err
 	}
-
-	// Down migrations
-	downMigrations, err := downMigrations(files)
-	if err != nil {
+	if err := checkDirty(db, tableName, config.namespace); err != nil {
 		return err
-	} else if len(downMigrations) == 0 {
-		return ErrNoMigrations
 	}
 
-	// Up migrations
-	upMigrations, err := upMigrations(files)
+	// get the current remote
+	remote, err := getRemoteVersion(db, tableName, config.namespace)
 	if err != nil {
 		return err
-	} else if len(upMigrations) == 0 {
+	} else if remote == 0 {
 		return ErrNoMigrations
 	}
 
 	// Redo the latest migration
-	downMigration := downMigrations[remote-1]
-	upMigration := upMigrations[remote-1]
-
-	tx, err := db.Begin()
+	upMigration := findMigration(upMigrations, remote)
+	if upMigration == nil {
+		return ErrNotEnoughMigrations
+	}
+	downMigration, err := set.reverse(remote)
 	if err != nil {
 		return err
 	}
-	defer tx.Rollback()
-
-	// execute the down migration
-	log.Info(downMigration.Name)
-	if _, err := tx.Exec(downMigration.Code); err != nil {
-		return format(downMigration, err)
-	}
-
-	// execute the up migration
-	log.Info(upMigration.Name)
-	if _, err := tx.Exec(upMigration.Code); err != nil {
-		return format(upMigration, err)
+	if len(set.Repeatables) > 0 {
+		if err := ensureChecksumTableExists(db, repeatableTable(tableName)); err != nil {
+			return err
+		}
 	}
 
-	return tx.Commit()
+	return config.run([]*Migration{downMigration, upMigration}, func() error {
+		return transact(db, tableName, config.namespace, []uint{remote}, func(tx *sql.Tx) error {
+			// execute the down migration
+			if err := config.exec(tx, downMigration); err != nil {
+				return err
+			}
+			if err := insertHistory(tx, tableName, config.namespace, downMigration, string(DirectionDown)); err != nil {
+				return err
+			}
+			if len(set.Repeatables) > 0 {
+				if err := clearRepeatables(tx, tableName, config.namespace); err != nil {
+					return err
+				}
+			}
+
+			// execute the up migration
+			if err := config.exec(tx, upMigration); err != nil {
+				return err
+			}
+			return insertHistory(tx, tableName, config.namespace, upMigration, string(DirectionUp))
+		})
+	})
 }
 
 // Version gets the current version of migrate
@@ -328,6 +407,9 @@ func exists(fsys fs.FS, path string) error {
 }
 
 func getFiles(fsys fs.FS) (files map[string]string, err error) {
+	if sources, ok := fsys.(*sourcesFS); ok {
+		return sources.files()
+	}
 	if err := exists(fsys, "."); err != nil {
 		return files, err
 	}
@@ -374,26 +456,142 @@ func getVersion(filename string) (uint, error) {
 
 // get the direction
 func getDirection(filename string) (Direction, error) {
-	if strings.Contains(filename, "."+string(up)+".") {
-		return up, nil
+	if strings.Contains(filename, "."+string(DirectionUp)+".") {
+		return DirectionUp, nil
 	}
-	if strings.Contains(filename, "."+string(down)+".") {
-		return down, nil
+	if strings.Contains(filename, "."+string(DirectionDown)+".") {
+		return DirectionDown, nil
 	}
 	return "", errors.New("filepath must specify the direction up or down (e.g. 000_setup.up.sql)")
 }
 
 // ensure the table exists
 func ensureTableExists(db *sql.DB, tableName string) error {
-	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + tableName + " (version bigint not null primary key);"); err != nil {
+	if err := ensureSchemaExists(db, tableName); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + quote(tableName) + " (" + versionColumns + ");"); err != nil {
+		return err
+	}
+	// tables created by older versions of migrate
+	if err := ensureColumnExists(db, tableName, "dirty", "boolean not null default false"); err != nil {
+		return err
+	}
+	if err := ensureNamespaceColumn(db, tableName); err != nil {
+		return err
+	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS " + quote(historyTable(tableName)) + " (namespace text not null default '', version bigint not null, name text not null, source text not null default '', action text not null, created_at timestamp not null default current_timestamp);"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ensureSchemaExists validates the table name and creates its schema in
+// Postgres when the table name is schema-qualified
+func ensureSchemaExists(db *sql.DB, tableName string) error {
+	if err := validateTable(tableName); err != nil {
+		return err
+	}
+	schema, _ := splitTable(tableName)
+	if schema == "" || database.Dialect(db) != "postgres" {
+		return nil
+	}
+	if _, err := db.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdentifier(schema)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// versionColumns are the columns of the version table. Each namespace has its
+// own sequence of versions. Dirty rows are markers for unfinished migrations
+// rather than applied versions (see markDirty).
+const versionColumns = "namespace text not null default '', version bigint not null, dirty boolean not null default false, primary key (namespace, version)"
+
+// ensureNamespaceColumn upgrades version tables created before namespaces.
+// The primary key changes, so the table is rebuilt with the existing versions
+// moved into the default namespace.
+func ensureNamespaceColumn(db *sql.DB, tableName string) error {
+	columns, err := getColumns(db, tableName)
+	if err != nil {
+		return err
+	} else if hasColumn(columns, "namespace") {
+		return nil
+	}
+	upgrade := tableName + "_upgrade"
+	_, table := splitTable(tableName)
+	return transaction(db, func(tx *sql.Tx) error {
+		if _, err := tx.Exec("CREATE TABLE " + quote(upgrade) + " (" + versionColumns + ");"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO " + quote(upgrade) + " (version, dirty) SELECT version, dirty FROM " + quote(tableName)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DROP TABLE " + quote(tableName)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("ALTER TABLE " + quote(upgrade) + " RENAME TO " + quoteIdentifier(table)); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// ensure the column exists, adding it if necessary
+func ensureColumnExists(db *sql.DB, tableName, column, definition string) error {
+	columns, err := getColumns(db, tableName)
+	if err != nil {
+		return err
+	} else if hasColumn(columns, column) {
+		return nil
+	}
+	if _, err := db.Exec("ALTER TABLE " + quote(tableName) + " ADD COLUMN " + column + " " + definition); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getColumns returns the column names of a table
+func getColumns(db *sql.DB, tableName string) ([]string, error) {
+	rows, err := db.Query("SELECT * FROM " + quote(tableName) + " WHERE 1=0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+func hasColumn(columns []string, column string) bool {
+	for _, name := range columns {
+		if strings.EqualFold(name, column) {
+			return true
+		}
+	}
+	return false
+}
+
+// historyTable is the name of the table that records every change
+func historyTable(tableName string) string {
+	return tableName + "_history"
+}
+
+// trackingTables are the tables that migrate manages itself, without their
+// schema
+func trackingTables(tableName string) []string {
+	_, tableName = splitTable(tableName)
+	return []string{tableName, historyTable(tableName), seedsTable(tableName), repeatableTable(tableName)}
+}
+
+// insertHistory records a migration in the history table
+func insertHistory(tx *sql.Tx, tableName, namespace string, migration *Migration, action string) error {
+	if _, err := tx.Exec("INSERT INTO "+quote(historyTable(tableName))+" (namespace, version, name, source, action) VALUES ($1, $2, $3, $4, $5)", namespace, migration.Version, migration.Name, migration.Source, action); err != nil {
 		return err
 	}
 	return nil
 }
 
 // Version gets the version from postgres
-func getRemoteVersion(db *sql.DB, tableName string) (version uint, err error) {
-	err = db.QueryRow("SELECT version FROM " + tableName + " ORDER BY version DESC LIMIT 1").Scan(&version)
+func getRemoteVersion(db *sql.DB, tableName, namespace string) (version uint, err error) {
+	err = db.QueryRow("SELECT version FROM "+quote(tableName)+" WHERE namespace=$1 AND dirty=$2 ORDER BY version DESC LIMIT 1", namespace, false).Scan(&version)
 	switch {
 	case err == sql.ErrNoRows:
 		return 0, nil
@@ -404,17 +602,34 @@ func getRemoteVersion(db *sql.DB, tableName string) (version uint, err error) {
 	}
 }
 
+// getAppliedVersions returns all the applied versions, latest first
+func getAppliedVersions(db *sql.DB, tableName, namespace string) (versions []uint, err error) {
+	rows, err := db.Query("SELECT version FROM "+quote(tableName)+" WHERE namespace=$1 AND dirty=$2 ORDER BY version DESC", namespace, false)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version uint
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
 // insert a new version into the table
-func insertVersion(tx *sql.Tx, tableName string, version uint) error {
-	if _, err := tx.Exec("INSERT INTO "+tableName+" (version) VALUES ($1)", version); err != nil {
+func insertVersion(tx *sql.Tx, tableName, namespace string, version uint) error {
+	if _, err := tx.Exec("INSERT INTO "+quote(tableName)+" (namespace, version) VALUES ($1, $2)", namespace, version); err != nil {
 		return err
 	}
 	return nil
 }
 
 // delete a version from the table
-func deleteVersion(tx *sql.Tx, tableName string, version uint) error {
-	if _, err := tx.Exec("DELETE FROM "+tableName+" WHERE version=$1", version); err != nil {
+func deleteVersion(tx *sql.Tx, tableName, namespace string, version uint) error {
+	if _, err := tx.Exec("DELETE FROM "+quote(tableName)+" WHERE namespace=$1 AND version=$2", namespace, version); err != nil {
 		return err
 	}
 	return nil
@@ -514,18 +729,10 @@ type Direction string
 
 // Directions
 const (
-	up   Direction = "up"
-	down Direction = "down"
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
 )
 
-func upMigrations(files map[string]string) (migs []*Migration, err error) {
-	return toMigrations(files, up)
-}
-
-func downMigrations(files map[string]string) (migs []*Migration, err error) {
-	return toMigrations(files, down)
-}
-
 // migrations takes a file map and turns it into a sorted list of migrations
 func toMigrations(files map[string]string, d Direction) (migs []*Migration, err error) {
 	for path, code := range files {
@@ -554,11 +761,40 @@ func toMigrations(files map[string]string, d Direction) (migs []*Migration, err
 		})
 	}
 	sort.Slice(migs, func(i, j int) bool {
-		return migs[i].Version < migs[j].Version
+		if migs[i].Version != migs[j].Version {
+			return migs[i].Version < migs[j].Version
+		}
+		return migs[i].Name < migs[j].Name
 	})
 	return migs, nil
 }
 
+// findMigration finds a migration by version
+func findMigration(migrations []*Migration, version uint) *Migration {
+	i := sort.Search(len(migrations), func(i int) bool {
+		return migrations[i].Version >= version
+	})
+	if i < len(migrations) && migrations[i].Version == version {
+		return migrations[i]
+	}
+	return nil
+}
+
+// reverse finds the down migration for version, matching by version rather
+// than by position. It fails if the down migration is missing or marked
+// irreversible.
+func (s *Set) reverse(version uint) (*Migration, error) {
+	pair := s.Find(version)
+	if pair == nil {
+		return nil, ErrNotEnoughMigrations
+	} else if pair.Down == nil {
+		return nil, fmt.Errorf("%w: %s has no down migration", ErrIrreversible, pair.Up.Name)
+	} else if pair.Irreversible() {
+		return nil, fmt.Errorf("%w: %s is marked irreversible", ErrIrreversible, pair.Up.Name)
+	}
+	return pair.Down, nil
+}
+
 func logger(l *slog.Logger) *slog.Logger {
 	if l == nil {
 		return logs.Discard()
@@ -1,12 +1,21 @@
 package migrate_test
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"github.com/matryer/is"
 	"github.com/matthewmueller/migrate"
@@ -76,7 +85,13 @@ func drop(t testing.TB, url string) {
 	db, close := connect(t, url)
 	defer close()
 	_, err := db.Exec(`
+		drop view if exists team_names;
 		drop table if exists migrate;
+		drop table if exists migrate_history;
+		drop table if exists migrate_seeds;
+		drop table if exists migrate_repeatable;
+		drop table if exists members;
+		drop table if exists posts;
 		drop table if exists users;
 		drop table if exists teams;
 	`)
@@ -100,6 +115,35 @@ func syntaxError(err error, name string) bool {
 		strings.Contains(err.Error(), fmt.Sprintf(`near "%s": syntax error`, name))
 }
 
+type recorder struct {
+	migrate.NopHooks
+	calls []string
+	abort string
+}
+
+func (r *recorder) BeforeRun(run *migrate.Run) error {
+	r.calls = append(r.calls, fmt.Sprintf("before run %d", len(run.Migrations)))
+	return nil
+}
+
+func (r *recorder) BeforeMigration(migration *migrate.Migration) error {
+	r.calls = append(r.calls, "before "+migration.Name)
+	return nil
+}
+
+func (r *recorder) AfterMigration(migration *migrate.Migration, duration time.Duration, err error) error {
+	r.calls = append(r.calls, "after "+migration.Name)
+	if migration.Name == r.abort {
+		return errors.New("aborted by hook")
+	}
+	return nil
+}
+
+func (r *recorder) AfterRun(run *migrate.Run, err error) error {
+	r.calls = append(r.calls, fmt.Sprintf("after run %v", err))
+	return nil
+}
+
 var tests = []struct {
 	name string
 	fn   func(t testing.TB, url string)
@@ -122,13 +166,47 @@ var tests = []struct {
 			drop(t, url)
 			is := is.New(t)
 			fs := fstest.MapFS{
-				"migrate/001_init.up.sql":   {Data: []byte(``)},
-				"migrate/001_init.down.sql": {Data: []byte(``)},
+				"init.up.sql":   {Data: []byte(``)},
+				"init.down.sql": {Data: []byte(``)},
+				"schema.sql":    {Data: []byte(`-- Generated by migrate. DO NOT EDIT.`)},
 			}
 			db, close := connect(t, url)
 			defer close()
 			err := migrate.Up(nil, db, fs, tableName)
-			is.Equal(migrate.ErrNoMigrations, err)
+			is.True(errors.Is(err, migrate.ErrNoMigrations))
+			is.True(strings.HasSuffix(err.Error(), "no migrations matching 001_name.up.sql or 001_name.down.sql, found init.down.sql, init.up.sql"))
+
+			// the schema dump isn't a misnamed migration
+			err = migrate.Up(nil, db, fstest.MapFS{"schema.sql": fs["schema.sql"]}, tableName)
+			is.Equal(err, migrate.ErrNoMigrations)
+		},
+	},
+	{
+		name: "nested migrations",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+			fsys := fstest.MapFS{
+				"migrate/001_init.up.sql":   {Data: []byte(`create table teams (id integer primary key not null);`)},
+				"migrate/001_init.down.sql": {Data: []byte(`drop table teams;`)},
+				"main.go":                   {Data: []byte(`package main`)},
+			}
+			db, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, db, fsys, tableName))
+			remote, err := migrate.RemoteVersion(db, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+			// the same migrations through Embed
+			remote, err = migrate.RemoteVersion(db, migrate.Embed(fsys, "migrate"), tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+			is.NoErr(migrate.Down(nil, db, fsys, tableName))
+			// more than one directory is ambiguous
+			fsys["other/001_init.up.sql"] = &fstest.MapFile{Data: []byte(``)}
+			err = migrate.Up(nil, db, fsys, tableName)
+			is.True(err != nil)
+			is.True(strings.Contains(err.Error(), "found migrations in more than one directory (migrate, other)"))
 		},
 	},
 	{
@@ -149,13 +227,14 @@ var tests = []struct {
 			drop(t, url)
 			is := is.New(t)
 			fs := fstest.MapFS{
-				"migrate/001_init.up.sql":   {Data: []byte(``)},
-				"migrate/001_init.down.sql": {Data: []byte(``)},
+				"migrate/init.up.sql":   {Data: []byte(``)},
+				"migrate/init.down.sql": {Data: []byte(``)},
 			}
 			db, close := connect(t, url)
 			defer close()
 			err := migrate.Down(nil, db, fs, tableName)
-			is.Equal(migrate.ErrNoMigrations, err)
+			is.True(errors.Is(err, migrate.ErrNoMigrations))
+			is.True(strings.Contains(err.Error(), "found migrate/init.down.sql, migrate/init.up.sql"))
 		},
 	},
 	{
@@ -578,6 +657,1315 @@ var tests = []struct {
 			is.NoErr(err)
 		},
 	},
+	{
+		name: "down missing down file",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table if not exists teams (
+							id serial primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+				"002_users.up.sql": {
+					Data: []byte(`
+						create table if not exists users (
+							id serial primary key not null,
+							email text not null
+						);
+					`),
+				},
+				"003_posts.up.sql": {
+					Data: []byte(`
+						create table if not exists posts (
+							id serial primary key not null,
+							title text not null
+						);
+					`),
+				},
+				"003_posts.down.sql": {
+					Data: []byte(`
+						drop table if exists posts;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+
+			// 002 has no down file, so nothing should run
+			err := migrate.Down(nil, db, fs, tableName)
+			is.True(errors.Is(err, migrate.ErrIrreversible))
+			is.True(strings.Contains(err.Error(), "002_users.up.sql"))
+			_, err = db.Exec(`insert into posts (id, title) values (1, 'hi')`)
+			is.NoErr(err)
+
+			// 003 can still be reverted on its own
+			is.NoErr(migrate.DownBy(nil, db, fs, tableName, 1))
+			_, err = db.Exec(`insert into posts (id, title) values (2, 'hi')`)
+			is.True(notExists(err, "posts"))
+			_, err = db.Exec(`insert into users (id, email) values (1, 'jack')`)
+			is.NoErr(err)
+
+			name, err := migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(`002_users.up.sql`, name)
+			_, err = db.Exec(`drop table if exists posts`)
+			is.NoErr(err)
+		},
+	},
+	{
+		name: "down without any down files",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+			fs := fstest.MapFS{
+				"001_teams.up.sql": {Data: []byte(`create table teams (id integer primary key not null);`)},
+				"002_users.up.sql": {Data: []byte(`create table users (id integer primary key not null);`)},
+			}
+			db, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			err := migrate.Down(nil, db, fs, tableName)
+			is.True(errors.Is(err, migrate.ErrIrreversible))
+			is.Equal(err.Error(), "irreversible migration: 002_users.up.sql has no down migration")
+			err = migrate.Redo(nil, db, fs, tableName)
+			is.True(errors.Is(err, migrate.ErrIrreversible))
+			is.True(strings.Contains(err.Error(), "002_users.up.sql"))
+		},
+	},
+	{
+		name: "down irreversible",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table if not exists teams (
+							id serial primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						-- migrate:irreversible
+					`),
+				},
+				"002_users.up.sql": {
+					Data: []byte(`
+						create table if not exists users (
+							id serial primary key not null,
+							email text not null
+						);
+					`),
+				},
+				"002_users.down.sql": {
+					Data: []byte(`
+						drop table if exists users;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+
+			err := migrate.Down(nil, db, fs, tableName)
+			is.True(errors.Is(err, migrate.ErrIrreversible))
+			is.True(strings.Contains(err.Error(), "001_init.up.sql"))
+
+			// users should still exist because nothing ran
+			_, err = db.Exec(`insert into users (id, email) values (1, 'jack')`)
+			is.NoErr(err)
+
+			name, err := migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(`002_users.up.sql`, name)
+		},
+	},
+	{
+		name: "dump",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_users.up.sql": {
+					Data: []byte(`
+						create table if not exists users (
+							id serial primary key not null,
+							email text not null
+						);
+						create index users_email on users (email);
+					`),
+				},
+				"001_users.down.sql": {
+					Data: []byte(`
+						drop table if exists users;
+					`),
+				},
+				"002_teams.up.sql": {
+					Data: []byte(`
+						create table if not exists teams (
+							id serial primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"002_teams.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+
+			schema, err := migrate.Dump(db, tableName)
+			is.NoErr(err)
+			is.True(strings.HasPrefix(schema, "-- Generated by migrate. DO NOT EDIT.\n-- Version: 002\n"))
+			is.True(strings.Index(schema, "teams") < strings.Index(schema, "users"))
+			is.True(strings.Contains(schema, "users_email"))
+			is.True(!strings.Contains(schema, "version bigint"))
+
+			again, err := migrate.Dump(db, tableName)
+			is.NoErr(err)
+			is.Equal(schema, again)
+		},
+	},
+	{
+		name: "new diff",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			dir := t.TempDir()
+			fsys := virt.OS(dir)
+			is.NoErr(fsys.WriteFile("001_users.up.sql", []byte(`create table users (id integer primary key not null, email text not null);`), 0644))
+			is.NoErr(fsys.WriteFile("001_users.down.sql", []byte(`drop table users;`), 0644))
+
+			current, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, current, fsys, tableName))
+
+			desired, closeDesired, err := db.Scratch(url)
+			is.NoErr(err)
+			defer func() { is.NoErr(closeDesired()) }()
+			// members references teams, which comes after it alphabetically, and
+			// teams has a serial id that the down migration drops
+			_, err = desired.Exec(`
+				create table users (id integer primary key not null, email text not null, name text);
+				create table teams (id serial primary key not null, name text not null);
+				create table members (id integer primary key not null, team_id integer not null references teams (id));
+			`)
+			is.NoErr(err)
+
+			paths, err := migrate.NewDiff(nil, fsys, "add teams", current, desired, tableName)
+			is.NoErr(err)
+			is.Equal(paths, []string{"002_add_teams.up.sql", "002_add_teams.down.sql"})
+			up, err := os.ReadFile(filepath.Join(dir, "002_add_teams.up.sql"))
+			is.NoErr(err)
+			is.True(strings.Contains(string(up), "teams"))
+			is.True(strings.Contains(string(up), `ADD COLUMN "name"`))
+			is.True(!strings.Contains(string(up), "WARNING"))
+			down, err := os.ReadFile(filepath.Join(dir, "002_add_teams.down.sql"))
+			is.NoErr(err)
+			is.True(strings.Contains(string(down), "-- WARNING: destructive, drops the teams table"))
+
+			// apply the generated migration
+			is.NoErr(migrate.Up(nil, current, fsys, tableName))
+			_, err = current.Exec(`insert into teams (id, name) values (1, 'jack')`)
+			is.NoErr(err)
+			_, err = current.Exec(`insert into users (id, email, name) values (1, 'jack@example.com', 'jack')`)
+			is.NoErr(err)
+			_, err = current.Exec(`insert into members (id, team_id) values (1, 1)`)
+			is.NoErr(err)
+
+			// nothing left to change
+			_, _, err = migrate.Diff(current, desired, tableName)
+			is.True(errors.Is(err, migrate.ErrNoChanges))
+
+			is.NoErr(migrate.DownBy(nil, current, fsys, tableName, 1))
+			_, err = current.Exec(`insert into teams (id, name) values (2, 'jack')`)
+			is.True(notExists(err, "teams"))
+			_, err = current.Exec(`insert into members (id, team_id) values (2, 1)`)
+			is.True(notExists(err, "members"))
+		},
+	},
+	{
+		name: "squash",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			dir := t.TempDir()
+			fsys := virt.OS(dir)
+			teams := "-- teams\n\tcreate table teams (id integer primary key not null, name text not null);\n\n"
+			is.NoErr(fsys.WriteFile("001_teams.up.sql", []byte(teams), 0644))
+			is.NoErr(fsys.WriteFile("001_teams.down.sql", []byte(`drop table teams;`), 0644))
+			is.NoErr(fsys.WriteFile("002_users.up.sql", []byte(`create table users (id integer primary key not null, email text not null);`), 0644))
+			is.NoErr(fsys.WriteFile("002_users.down.sql", []byte(`drop table users;`), 0644))
+			is.NoErr(fsys.WriteFile("003_posts.up.sql", []byte(`create table posts (id integer primary key not null, title text not null);`), 0644))
+			is.NoErr(fsys.WriteFile("003_posts.down.sql", []byte(`drop table posts;`), 0644))
+
+			// existing database that's past the squash
+			db1, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, db1, fsys, tableName))
+
+			scratch, closeScratch, err := db.Scratch(url)
+			is.NoErr(err)
+			defer func() { is.NoErr(closeScratch()) }()
+
+			// earlier archives aren't overwritten
+			is.NoErr(fsys.MkdirAll("archive", 0755))
+			is.NoErr(fsys.WriteFile("archive/002_users.down.sql", []byte(`drop table old_users;`), 0644))
+			err = migrate.Squash(nil, scratch, fsys, tableName, 2)
+			is.True(err != nil)
+			is.Equal(err.Error(), "unable to archive 002_users.down.sql, archive/002_users.down.sql already exists")
+			exists(t, filepath.Join(dir, "001_teams.up.sql"))
+			_, err = os.Stat(filepath.Join(dir, "002_baseline.up.sql"))
+			is.True(errors.Is(err, os.ErrNotExist))
+			is.NoErr(fsys.RemoveAll("archive"))
+
+			is.NoErr(migrate.Squash(nil, scratch, fsys, tableName, 2))
+
+			exists(t, filepath.Join(dir, "002_baseline.up.sql"))
+			exists(t, filepath.Join(dir, "002_baseline.down.sql"))
+			exists(t, filepath.Join(dir, "archive", "001_teams.up.sql"))
+			exists(t, filepath.Join(dir, "archive", "002_users.down.sql"))
+			_, err = os.Stat(filepath.Join(dir, "001_teams.up.sql"))
+			is.True(errors.Is(err, os.ErrNotExist))
+
+			// the originals are archived byte for byte
+			archived, err := os.ReadFile(filepath.Join(dir, "archive", "001_teams.up.sql"))
+			is.NoErr(err)
+			is.Equal(string(archived), teams)
+
+			// the baseline doesn't re-run on the existing database
+			is.NoErr(migrate.Up(nil, db1, fsys, tableName))
+			name, err := migrate.RemoteVersion(db1, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(`003_posts.up.sql`, name)
+			is.NoErr(migrate.Down(nil, db1, fsys, tableName))
+			_, err = db1.Exec(`insert into teams (id, name) values (1, 'jack')`)
+			is.True(notExists(err, "teams"))
+
+			// fresh databases start from the baseline
+			is.NoErr(migrate.Up(nil, db1, fsys, tableName))
+			_, err = db1.Exec(`insert into teams (id, name) values (1, 'jack')`)
+			is.NoErr(err)
+			_, err = db1.Exec(`insert into posts (id, title) values (1, 'hi')`)
+			is.NoErr(err)
+			is.NoErr(migrate.Down(nil, db1, fsys, tableName))
+		},
+	},
+	{
+		name: "squash foreign keys",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			dir := t.TempDir()
+			fsys := virt.OS(dir)
+			is.NoErr(fsys.WriteFile("001_users.up.sql", []byte(`create table users (id serial primary key not null, email text not null);`), 0644))
+			is.NoErr(fsys.WriteFile("001_users.down.sql", []byte(`drop table users;`), 0644))
+			is.NoErr(fsys.WriteFile("002_posts.up.sql", []byte(`create table posts (id serial primary key not null, user_id integer not null references users (id));`), 0644))
+			is.NoErr(fsys.WriteFile("002_posts.down.sql", []byte(`drop table posts;`), 0644))
+
+			// existing database built from the originals
+			db1, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, db1, fsys, tableName))
+
+			scratch, closeScratch, err := db.Scratch(url)
+			is.NoErr(err)
+			defer func() { is.NoErr(closeScratch()) }()
+			is.NoErr(migrate.Squash(nil, scratch, fsys, tableName, 2))
+			baseline, err := os.ReadFile(filepath.Join(dir, "002_baseline.down.sql"))
+			is.NoErr(err)
+			is.True(!strings.Contains(string(baseline), "DROP SEQUENCE"))
+
+			// the baseline's down migration works on the existing database
+			is.NoErr(migrate.Down(nil, db1, fsys, tableName))
+			_, err = db1.Exec(`insert into users (id, email) values (1, 'jack')`)
+			is.True(notExists(err, "users"))
+
+			// posts references users, which comes after it alphabetically
+			is.NoErr(migrate.Up(nil, db1, fsys, tableName))
+			_, err = db1.Exec(`insert into users (id, email) values (1, 'jack')`)
+			is.NoErr(err)
+			_, err = db1.Exec(`insert into posts (id, user_id) values (1, 1)`)
+			is.NoErr(err)
+			is.NoErr(migrate.Down(nil, db1, fsys, tableName))
+		},
+	},
+	{
+		name: "baseline",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+				"002_users.up.sql": {
+					Data: []byte(`
+						create table users (
+							id integer primary key not null,
+							email text not null
+						);
+					`),
+				},
+				"002_users.down.sql": {
+					Data: []byte(`
+						drop table if exists users;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			// built by hand
+			_, err := db.Exec(`create table teams (id integer primary key not null, name text not null)`)
+			is.NoErr(err)
+
+			is.NoErr(migrate.Baseline(nil, db, fs, tableName, 1, false))
+			name, err := migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(`001_init.up.sql`, name)
+
+			// refuses to run twice
+			err = migrate.Baseline(nil, db, fs, tableName, 1, false)
+			is.True(errors.Is(err, migrate.ErrAlreadyMigrated))
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			_, err = db.Exec(`insert into users (id, email) values (1, 'jack')`)
+			is.NoErr(err)
+
+			// forced
+			is.NoErr(migrate.Baseline(nil, db, fs, tableName, 2, true))
+			name, err = migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(`002_users.up.sql`, name)
+		},
+	},
+	{
+		name: "dirty force",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+				"002_users.up.sql": {
+					Data: []byte(`
+						create table users (
+							id integer primary key not null,
+							email text not null
+						);
+					`),
+				},
+				"002_users.down.sql": {
+					Data: []byte(`
+						drop table if exists users;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			is.NoErr(migrate.UpBy(nil, db, fs, tableName, 1))
+
+			// a failed migration doesn't leave the database dirty
+			fs["002_users.up.sql"] = &fstest.MapFile{Data: []byte(`create table users (;`)}
+			err := migrate.Up(nil, db, fs, tableName)
+			is.True(err != nil)
+			is.True(!errors.Is(err, migrate.ErrDirty))
+
+			// simulate a process that died partway through 002 and 003
+			fs["003_posts.up.sql"] = &fstest.MapFile{Data: []byte(`create table posts (id integer primary key not null);`)}
+			_, err = db.Exec(`insert into migrate (version, dirty) values (-2, true), (-3, true)`)
+			is.NoErr(err)
+			err = migrate.Up(nil, db, fs, tableName)
+			is.True(errors.Is(err, migrate.ErrDirty))
+			is.True(strings.Contains(err.Error(), "002, 003 didn't finish migrating"))
+			// the unfinished versions aren't reported as applied
+			name, err := migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(name, "001_init.up.sql")
+			statuses, err := migrate.Status(db, fs, tableName)
+			is.NoErr(err)
+			is.True(statuses[0].Applied)
+			is.True(!statuses[1].Applied)
+			is.True(!statuses[2].Applied)
+			err = migrate.Down(nil, db, fs, tableName)
+			is.True(errors.Is(err, migrate.ErrDirty))
+
+			// repair and force back to 001
+			is.NoErr(migrate.Force(nil, db, tableName, 1))
+			fs["002_users.up.sql"] = &fstest.MapFile{Data: []byte(`create table users (id integer primary key not null, email text not null);`)}
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			name, err = migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(`003_posts.up.sql`, name)
+
+			var count int
+			err = db.QueryRow(`select count(*) from migrate_history where action = 'force'`).Scan(&count)
+			is.NoErr(err)
+			is.Equal(1, count)
+		},
+	},
+	{
+		name: "upgrade migrate table",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			// created by an older version of migrate
+			_, err := db.Exec(`create table migrate (version bigint not null primary key)`)
+			is.NoErr(err)
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			is.NoErr(migrate.Down(nil, db, fs, tableName))
+		},
+	},
+	{
+		name: "hooks",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+				"002_users.up.sql": {
+					Data: []byte(`
+						create table users (
+							id integer primary key not null,
+							email text not null
+						);
+					`),
+				},
+				"002_users.down.sql": {
+					Data: []byte(`
+						drop table if exists users;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			// hook errors roll back the run
+			hooks := &recorder{abort: "002_users.up.sql"}
+			err := migrate.Up(nil, db, fs, tableName, migrate.WithHooks(hooks))
+			is.True(err != nil)
+			is.Equal(err.Error(), "aborted by hook")
+			is.Equal(hooks.calls, []string{
+				"before run 2",
+				"before 001_init.up.sql",
+				"after 001_init.up.sql",
+				"before 002_users.up.sql",
+				"after 002_users.up.sql",
+				"after run aborted by hook",
+			})
+			_, err = db.Exec(`insert into teams (id, name) values (1, 'jack')`)
+			is.True(notExists(err, "teams"))
+
+			hooks = &recorder{}
+			is.NoErr(migrate.Up(nil, db, fs, tableName, migrate.WithHooks(hooks)))
+			is.Equal(len(hooks.calls), 6)
+			is.Equal(hooks.calls[5], "after run <nil>")
+
+			hooks = &recorder{}
+			is.NoErr(migrate.DownBy(nil, db, fs, tableName, 1, migrate.WithHooks(hooks)))
+			is.Equal(hooks.calls, []string{
+				"before run 1",
+				"before 002_users.down.sql",
+				"after 002_users.down.sql",
+				"after run <nil>",
+			})
+		},
+	},
+	{
+		name: "structured logs",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null -- a comment; with a semicolon
+						);
+						insert into teams (id, name) values (1, 'a;b');
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			buf := new(bytes.Buffer)
+			log := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			is.NoErr(migrate.Up(log, db, fs, tableName))
+
+			var records []map[string]any
+			dec := json.NewDecoder(buf)
+			for dec.More() {
+				var record map[string]any
+				is.NoErr(dec.Decode(&record))
+				records = append(records, record)
+			}
+			is.Equal(len(records), 3)
+			is.Equal(records[0]["level"], "DEBUG")
+			is.True(strings.Contains(records[0]["sql"].(string), "insert into teams"))
+			is.Equal(records[1]["name"], "001_init.up.sql")
+			is.Equal(records[1]["version"], float64(1))
+			is.Equal(records[1]["direction"], "up")
+			is.Equal(records[1]["statements"], float64(2))
+			is.True(records[1]["duration"] != nil)
+			is.Equal(records[2]["msg"], "migrations committed")
+			is.Equal(records[2]["migrations"], float64(1))
+		},
+	},
+	{
+		name: "seed",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null
+						);
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop table if exists teams;
+					`),
+				},
+			}
+			seeds := fstest.MapFS{
+				"001_teams.sql": {
+					Data: []byte(`insert into teams (id, name) values (1, 'jack');`),
+				},
+				"002_refresh.sql": {
+					Data: []byte(`
+						-- migrate:always
+						update teams set name = name || '!';
+					`),
+				},
+				"README.md": {Data: []byte(`not a seed`)},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			err := migrate.Seed(nil, db, fstest.MapFS{}, tableName)
+			is.Equal(err, migrate.ErrNoSeeds)
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			is.NoErr(migrate.Seed(nil, db, seeds, tableName))
+			is.NoErr(migrate.Seed(nil, db, seeds, tableName))
+
+			var name string
+			is.NoErr(db.QueryRow(`select name from teams where id = 1`).Scan(&name))
+			is.Equal(name, "jack!!")
+
+			// seeds don't affect the schema version
+			remote, err := migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+		},
+	},
+	{
+		name: "repeatable",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql": {
+					Data: []byte(`
+						create table teams (
+							id integer primary key not null,
+							name text not null
+						);
+						insert into teams (id, name) values (1, 'jack');
+					`),
+				},
+				"001_init.down.sql": {
+					Data: []byte(`
+						drop view if exists team_names;
+						drop table if exists teams;
+					`),
+				},
+				"R_team_names.sql": {
+					Data: []byte(`
+						drop view if exists team_names;
+						create view team_names as select name from teams;
+					`),
+				},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			hooks := &recorder{}
+			is.NoErr(migrate.Up(nil, db, fs, tableName, migrate.WithHooks(hooks)))
+			is.Equal(hooks.calls[0], "before run 2")
+			var name string
+			is.NoErr(db.QueryRow(`select name from team_names`).Scan(&name))
+			is.Equal(name, "jack")
+
+			// unchanged, so nothing runs
+			hooks = &recorder{}
+			is.NoErr(migrate.Up(nil, db, fs, tableName, migrate.WithHooks(hooks)))
+			is.Equal(len(hooks.calls), 0)
+
+			// changed, so it's re-applied
+			fs["R_team_names.sql"] = &fstest.MapFile{
+				Data: []byte(`
+					drop view if exists team_names;
+					create view team_names as select upper(name) as name from teams;
+				`),
+			}
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			is.NoErr(db.QueryRow(`select name from team_names`).Scan(&name))
+			is.Equal(name, "JACK")
+
+			remote, err := migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+
+			// rolling back forgets the repeatables, so they're re-applied
+			is.NoErr(migrate.Down(nil, db, fs, tableName))
+			_, err = db.Exec(`select * from team_names`)
+			is.True(notExists(err, "team_names"))
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			is.NoErr(db.QueryRow(`select name from team_names`).Scan(&name))
+			is.Equal(name, "JACK")
+
+			// so does redo
+			is.NoErr(migrate.Redo(nil, db, fs, tableName))
+			_, err = db.Exec(`select * from team_names`)
+			is.True(notExists(err, "team_names"))
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			is.NoErr(db.QueryRow(`select name from team_names`).Scan(&name))
+			is.Equal(name, "JACK")
+			is.NoErr(migrate.Down(nil, db, fs, tableName))
+		},
+	},
+	{
+		name: "sources",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			app := fstest.MapFS{
+				"001_teams.up.sql":   {Data: []byte(`create table teams (id integer primary key not null, name text not null);`)},
+				"001_teams.down.sql": {Data: []byte(`drop table if exists teams;`)},
+				"003_posts.up.sql":   {Data: []byte(`create table posts (id integer primary key not null, title text not null);`)},
+				"003_posts.down.sql": {Data: []byte(`drop table if exists posts;`)},
+			}
+			auth := fstest.MapFS{
+				"002_users.up.sql":   {Data: []byte(`create table users (id integer primary key not null, email text not null);`)},
+				"002_users.down.sql": {Data: []byte(`drop table if exists users;`)},
+			}
+			fs := migrate.Sources(
+				migrate.Source{Name: "app", FS: app},
+				migrate.Source{Name: "auth", FS: auth},
+			)
+
+			db, close := connect(t, url)
+			defer close()
+
+			is.NoErr(migrate.Up(nil, db, fs, tableName))
+			local, err := migrate.LocalVersion(fs)
+			is.NoErr(err)
+			is.Equal(local, "003_posts.up.sql")
+
+			statuses, err := migrate.Status(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(len(statuses), 3)
+			is.Equal(statuses[0].Source, "app")
+			is.Equal(statuses[1].Source, "auth")
+			is.Equal(statuses[1].Name, "002_users.up.sql")
+			is.True(statuses[1].Applied)
+			is.Equal(statuses[2].Source, "app")
+
+			var source string
+			is.NoErr(db.QueryRow(`select source from migrate_history where version = 2`).Scan(&source))
+			is.Equal(source, "auth")
+
+			// duplicate versions across sources
+			auth["001_users.up.sql"] = &fstest.MapFile{Data: []byte(`create table users (id integer);`)}
+			err = migrate.Up(nil, db, fs, tableName)
+			is.True(err != nil)
+			is.Equal(err.Error(), `version 001 is defined in both source "app" (001_teams.up.sql) and source "auth" (001_users.up.sql)`)
+
+			// missing and empty sources aren't skipped
+			err = migrate.Up(nil, db, migrate.Sources(
+				migrate.Source{Name: "app", FS: app},
+				migrate.Source{Name: "billing", FS: os.DirFS(filepath.Join(t.TempDir(), "billing"))},
+			), tableName)
+			is.True(errors.Is(err, migrate.ErrNoMigrations))
+			is.Equal(err.Error(), `no migrations in source "billing"`)
+			err = migrate.Up(nil, db, migrate.Sources(
+				migrate.Source{Name: "app", FS: app},
+				migrate.Source{Name: "billing", FS: fstest.MapFS{"README.md": {Data: []byte(`# billing`)}}},
+			), tableName)
+			is.True(errors.Is(err, migrate.ErrNoMigrations))
+			is.Equal(err.Error(), `no migrations in source "billing"`)
+
+			is.NoErr(migrate.Down(nil, db, migrate.Sources(
+				migrate.Source{Name: "app", FS: app},
+				migrate.Source{Name: "auth", FS: fstest.MapFS{
+					"002_users.up.sql":   auth["002_users.up.sql"],
+					"002_users.down.sql": auth["002_users.down.sql"],
+				}},
+			), tableName))
+		},
+	},
+	{
+		name: "namespaces",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			app := fstest.MapFS{
+				"001_teams.up.sql":   {Data: []byte(`create table teams (id integer primary key not null, name text not null);`)},
+				"001_teams.down.sql": {Data: []byte(`drop table if exists teams;`)},
+				"002_posts.up.sql":   {Data: []byte(`create table posts (id integer primary key not null, title text not null);`)},
+				"002_posts.down.sql": {Data: []byte(`drop table if exists posts;`)},
+			}
+			queue := fstest.MapFS{
+				"001_users.up.sql":   {Data: []byte(`create table users (id integer primary key not null, email text not null);`)},
+				"001_users.down.sql": {Data: []byte(`drop table if exists users;`)},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			// versions recorded by an older version of migrate
+			_, err := db.Exec(`create table migrate (version bigint not null primary key, dirty boolean not null default false)`)
+			is.NoErr(err)
+			_, err = db.Exec(`insert into migrate (version) values (1)`)
+			is.NoErr(err)
+			_, err = db.Exec(`create table teams (id integer primary key not null, name text not null)`)
+			is.NoErr(err)
+
+			is.NoErr(migrate.Up(nil, db, app, tableName))
+			is.NoErr(migrate.Up(nil, db, queue, tableName, migrate.WithNamespace("queue")))
+
+			remote, err := migrate.RemoteVersion(db, app, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "002_posts.up.sql")
+			remote, err = migrate.RemoteVersion(db, queue, tableName, migrate.WithNamespace("queue"))
+			is.NoErr(err)
+			is.Equal(remote, "001_users.up.sql")
+
+			var count int
+			is.NoErr(db.QueryRow(`select count(*) from migrate where version = 1`).Scan(&count))
+			is.Equal(count, 2)
+
+			// rolling back the queue leaves the app alone
+			is.NoErr(migrate.Down(nil, db, queue, tableName, migrate.WithNamespace("queue")))
+			_, err = db.Exec(`select * from users`)
+			is.True(notExists(err, "users"))
+			remote, err = migrate.RemoteVersion(db, app, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "002_posts.up.sql")
+			_, err = db.Exec(`select * from posts`)
+			is.NoErr(err)
+
+			// repeatables are tracked per namespace
+			view := &fstest.MapFile{Data: []byte(`drop view if exists team_names; create view team_names as select name from teams;`)}
+			app["R_team_names.sql"] = view
+			queue["R_team_names.sql"] = view
+			is.NoErr(migrate.Up(nil, db, app, tableName))
+			is.NoErr(migrate.Up(nil, db, queue, tableName, migrate.WithNamespace("queue")))
+			is.NoErr(db.QueryRow(`select count(*) from migrate_repeatable where name = 'R_team_names.sql'`).Scan(&count))
+			is.Equal(count, 2)
+			_, err = db.Exec(`drop view team_names`)
+			is.NoErr(err)
+
+			// seeds are tracked per namespace
+			seeds := fstest.MapFS{
+				"001_teams.sql": {Data: []byte(`insert into teams (id, name) values ((select count(*) + 1 from teams), 'jack');`)},
+			}
+			is.NoErr(migrate.Seed(nil, db, seeds, tableName))
+			is.NoErr(migrate.Seed(nil, db, seeds, tableName))
+			is.NoErr(db.QueryRow(`select count(*) from teams`).Scan(&count))
+			is.Equal(count, 1)
+			is.NoErr(migrate.Seed(nil, db, seeds, tableName, migrate.WithNamespace("queue")))
+			is.NoErr(migrate.Seed(nil, db, seeds, tableName, migrate.WithNamespace("queue")))
+			is.NoErr(db.QueryRow(`select count(*) from teams`).Scan(&count))
+			is.Equal(count, 2)
+		},
+	},
+	{
+		name: "table names",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql":   {Data: []byte(`create table teams (id integer primary key not null, name text not null);`)},
+				"001_init.down.sql": {Data: []byte(`drop table if exists teams;`)},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			// invalid table names are rejected before they reach SQL
+			err := migrate.Up(nil, db, fs, "migrate; drop table users")
+			is.True(err != nil)
+			is.Equal(err.Error(), `invalid table name "migrate; drop table users", names may only contain letters, digits and underscores`)
+			err = migrate.Up(nil, db, fs, "a.b.c")
+			is.True(err != nil)
+			is.Equal(err.Error(), `invalid table name "a.b.c", expected table or schema.table`)
+
+			// reserved words are quoted
+			is.NoErr(migrate.Up(nil, db, fs, "order"))
+			remote, err := migrate.RemoteVersion(db, fs, "order")
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+			is.NoErr(migrate.Down(nil, db, fs, "order"))
+			_, err = db.Exec(`drop table "order"; drop table "order_history";`)
+			is.NoErr(err)
+
+			// schema-qualified table names
+			schema := "public"
+			if strings.HasPrefix(url, "sqlite") {
+				schema = "main"
+			}
+			is.NoErr(migrate.Up(nil, db, fs, schema+"."+tableName))
+			remote, err = migrate.RemoteVersion(db, fs, schema+"."+tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+			remote, err = migrate.RemoteVersion(db, fs, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+
+			// upper-case table names are folded to lowercase, like Postgres folds
+			// unquoted names
+			remote, err = migrate.RemoteVersion(db, fs, "Migrate")
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+			remote, err = migrate.RemoteVersion(db, fs, schema+".MIGRATE")
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+
+			// sqlite doesn't have a search path
+			if strings.HasPrefix(url, "sqlite") {
+				err = migrate.Up(nil, db, fs, tableName, migrate.WithSearchPath("main"))
+				is.True(err != nil)
+				is.Equal(err.Error(), "unable to set the search path, sqlite doesn't support schemas")
+			}
+		},
+	},
+	{
+		name: "tenants",
+		fn: func(t testing.TB, url string) {
+			if strings.HasPrefix(url, "sqlite") {
+				t.Skip("sqlite doesn't have schemas")
+			}
+			drop(t, url)
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql":   {Data: []byte(`create table teams (id serial primary key not null, name text not null);`)},
+				"001_init.down.sql": {Data: []byte(`drop table if exists teams;`)},
+			}
+
+			db, close := connect(t, url)
+			defer close()
+
+			_, err := db.Exec(`
+				drop schema if exists tenant_a cascade;
+				drop schema if exists tenant_b cascade;
+				create schema tenant_a;
+				create schema tenant_b;
+			`)
+			is.NoErr(err)
+			defer db.Exec(`drop schema if exists tenant_a cascade; drop schema if exists tenant_b cascade;`)
+
+			schemas, err := migrate.Schemas(db, "tenant_%")
+			is.NoErr(err)
+			is.Equal(schemas, []string{"tenant_a", "tenant_b"})
+
+			results := migrate.UpTenants(nil, db, fs, tableName, append(schemas, "tenant-c"), 2)
+			is.Equal(len(results), 3)
+			is.Equal(results[0].Target, "tenant_a")
+			is.NoErr(results[0].Err)
+			is.Equal(results[0].Version, uint(1))
+			is.Equal(results[1].Target, "tenant_b")
+			is.NoErr(results[1].Err)
+			is.Equal(results[2].Target, "tenant-c")
+			is.True(results[2].Err != nil)
+			is.Equal(len(migrate.Failed(results)), 1)
+
+			// each tenant has its own tables and versions
+			_, err = db.Exec(`insert into tenant_a.teams (name) values ('jack')`)
+			is.NoErr(err)
+			_, err = db.Exec(`insert into tenant_b.teams (name) values ('jill')`)
+			is.NoErr(err)
+			_, err = db.Exec(`select * from teams`)
+			is.True(notExists(err, "teams"))
+			remote, err := migrate.RemoteVersion(db, fs, "tenant_b."+tableName)
+			is.NoErr(err)
+			is.Equal(remote, "001_init.up.sql")
+		},
+	},
+	{
+		name: "db glob",
+		fn: func(t testing.TB, url string) {
+			if !strings.HasPrefix(url, "sqlite") {
+				t.Skip("db glob is for sqlite files")
+			}
+			is := is.New(t)
+
+			fs := fstest.MapFS{
+				"001_init.up.sql":   {Data: []byte(`create table teams (id integer primary key not null, name text not null);`)},
+				"001_init.down.sql": {Data: []byte(`drop table if exists teams;`)},
+			}
+
+			dir := t.TempDir()
+			for _, name := range []string{"a.db", "b.db", "c.db"} {
+				is.NoErr(os.WriteFile(filepath.Join(dir, name), nil, 0644))
+			}
+			// a.db already has the table, so the migration fails
+			broken, close := connect(t, "sqlite:"+filepath.Join(dir, "a.db"))
+			_, err := broken.Exec(`create table teams (id integer)`)
+			is.NoErr(err)
+			close()
+
+			results, err := migrate.UpGlob(nil, filepath.Join(dir, "*.db"), fs, tableName, 1, false)
+			is.NoErr(err)
+			is.Equal(len(results), 3)
+			is.Equal(results[0].Target, filepath.Join(dir, "a.db"))
+			is.True(results[0].Err != nil)
+			is.True(errors.Is(results[1].Err, migrate.ErrSkipped))
+			is.True(errors.Is(results[2].Err, migrate.ErrSkipped))
+
+			results, err = migrate.UpGlob(nil, filepath.Join(dir, "*.db"), fs, tableName, 2, true)
+			is.NoErr(err)
+			is.True(results[0].Err != nil)
+			is.NoErr(results[1].Err)
+			is.Equal(results[1].Version, uint(1))
+			is.NoErr(results[2].Err)
+			is.Equal(results[2].Target, filepath.Join(dir, "c.db"))
+			is.Equal(results[2].Version, uint(1))
+			is.Equal(len(migrate.Failed(results)), 1)
+
+			_, err = migrate.UpGlob(nil, filepath.Join(dir, "*.sqlite"), fs, tableName, 2, true)
+			is.True(err != nil)
+		},
+	},
+	{
+		name: "new scaffold",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			dialect := "postgres"
+			if strings.HasPrefix(url, "sqlite") {
+				dialect = "sqlite"
+			}
+			fsys := virt.OS(t.TempDir())
+			read := func(path string) string {
+				data, err := fs.ReadFile(fsys, path)
+				is.NoErr(err)
+				return string(data)
+			}
+
+			paths, err := migrate.New(nil, fsys, "create teams", migrate.WithDialect(dialect))
+			is.NoErr(err)
+			is.Equal(paths, []string{"001_create_teams.up.sql", "001_create_teams.down.sql"})
+			is.True(strings.HasPrefix(read(paths[0]), "create table teams ("))
+			is.Equal(read(paths[1]), "drop table if exists teams;\n")
+
+			paths, err = migrate.New(nil, fsys, "add_name_to_teams", migrate.WithDialect(dialect))
+			is.NoErr(err)
+			is.Equal(read(paths[0]), "alter table teams add column name text;\n")
+			is.Equal(read(paths[1]), "alter table teams drop column name;\n")
+
+			paths, err = migrate.New(nil, fsys, "add_index_on_teams_name", migrate.WithDialect(dialect))
+			is.NoErr(err)
+			is.Equal(read(paths[0]), "create index if not exists teams_name_idx on teams (name);\n")
+			is.Equal(read(paths[1]), "drop index if exists teams_name_idx;\n")
+
+			// the scaffolded migrations run
+			db, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, db, fsys, tableName))
+			_, err = db.Exec(`insert into teams (name) values ('jack')`)
+			is.NoErr(err)
+			is.NoErr(migrate.Down(nil, db, fsys, tableName))
+
+			paths, err = migrate.New(nil, fsys, "drop_posts")
+			is.NoErr(err)
+			is.Equal(read(paths[0]), "drop table if exists posts;\n")
+			is.Equal(read(paths[1]), "-- migrate:irreversible\n")
+
+			// project templates take precedence
+			is.NoErr(fsys.MkdirAll("templates", 0755))
+			is.NoErr(fsys.WriteFile("templates/create.up.sql", []byte(`create table {{ .Table }} (id uuid primary key);`), 0644))
+			is.NoErr(fsys.WriteFile("templates/default.up.sql", []byte(`-- {{ .Name }}`), 0644))
+			paths, err = migrate.New(nil, fsys, "create users")
+			is.NoErr(err)
+			is.Equal(read(paths[0]), "create table users (id uuid primary key);")
+			is.Equal(read(paths[1]), "drop table if exists users;\n")
+			paths, err = migrate.New(nil, fsys, "backfill users")
+			is.NoErr(err)
+			is.Equal(paths[0], "006_backfill_users.up.sql")
+			is.Equal(read(paths[0]), "-- backfill_users")
+			is.Equal(read(paths[1]), "")
+		},
+	},
+	{
+		name: "renumber",
+		fn: func(t testing.TB, url string) {
+			drop(t, url)
+			is := is.New(t)
+
+			fsys := virt.OS(t.TempDir())
+			write := func(name, code string) {
+				is.NoErr(fsys.WriteFile(name, []byte(code), 0644))
+			}
+			write("001_teams.up.sql", `create table teams (id integer primary key not null, name text not null);`)
+			write("001_teams.down.sql", `drop table teams;`)
+			write("002_users.up.sql", `create table users (id integer primary key not null, email text not null);`)
+			write("002_users.down.sql", `drop table users;`)
+
+			db, close := connect(t, url)
+			defer close()
+			is.NoErr(migrate.Up(nil, db, fsys, tableName))
+
+			// another branch added 002_posts and 003_comments
+			write("002_posts.up.sql", `create table posts (id integer primary key not null, title text not null);`)
+			write("002_posts.down.sql", `drop table posts;`)
+			write("003_comments.up.sql", `create table comments (id integer primary key not null);`)
+			write("003_comments.down.sql", `drop table comments;`)
+
+			renames, err := migrate.Renumber(nil, db, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(len(renames), 2)
+			is.Equal(*renames[0], migrate.Rename{From: "002_posts.down.sql", To: "004_posts.down.sql"})
+			is.Equal(*renames[1], migrate.Rename{From: "002_posts.up.sql", To: "004_posts.up.sql"})
+
+			// nothing left to do
+			renames, err = migrate.Renumber(nil, db, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(len(renames), 0)
+
+			is.NoErr(migrate.Up(nil, db, fsys, tableName))
+			remote, err := migrate.RemoteVersion(db, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(remote, "004_posts.up.sql")
+			_, err = db.Exec(`drop table comments`)
+			is.NoErr(err)
+
+			// without a database, only conflicts are moved
+			write("004_tags.up.sql", `select 1;`)
+			renames, err = migrate.Renumber(nil, nil, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(len(renames), 1)
+			is.Equal(*renames[0], migrate.Rename{From: "004_tags.up.sql", To: "005_tags.up.sql"})
+		},
+	},
+	{
+		name: "wait",
+		fn: func(t testing.TB, url string) {
+			is := is.New(t)
+			log := slog.New(slog.NewTextHandler(io.Discard, nil))
+			conn, close := connect(t, url)
+			defer close()
+			is.NoErr(db.Wait(context.Background(), log, conn, time.Second))
+			// a database that never becomes reachable
+			unreachable, close := connect(t, "sqlite:"+filepath.Join(t.TempDir(), "missing", "app.db"))
+			defer close()
+			start := time.Now()
+			err := db.Wait(context.Background(), log, unreachable, 300*time.Millisecond)
+			is.True(err != nil)
+			is.True(strings.Contains(err.Error(), "database wasn't reachable after 300ms"))
+			is.True(time.Since(start) < time.Second)
+		},
+	},
+	{
+		name: "load",
+		fn: func(t testing.TB, url string) {
+			is := is.New(t)
+			fsys := fstest.MapFS{
+				"001_teams.up.sql":   {Data: []byte(`create table teams (id integer primary key not null);`)},
+				"001_teams.down.sql": {Data: []byte(`drop table teams;`)},
+				"002_users.up.sql":   {Data: []byte("-- migrate:irreversible\ncreate table users (id integer primary key not null);")},
+				"002_users.down.sql": {Data: []byte(``)},
+				"003_posts.up.sql":   {Data: []byte(`create table posts (id integer primary key not null);`)},
+				"R_views.sql":        {Data: []byte(`select 1;`)},
+			}
+			set, err := migrate.Load(fsys)
+			is.NoErr(err)
+			is.Equal(len(set.Pairs), 3)
+			is.Equal(set.Pairs[0].Version, uint(1))
+			is.Equal(set.Pairs[0].Name, "teams")
+			is.Equal(set.Pairs[0].Up.Dir, migrate.DirectionUp)
+			is.Equal(set.Pairs[0].Down.Dir, migrate.DirectionDown)
+			is.Equal(set.Pairs[0].Down.Code, "drop table teams;")
+			is.Equal(set.Pairs[0].Up.Checksum(), "b6da7351a704346b2e0d2dbe50d6929e0c6c473719ae7975344c0b6f69836096")
+			is.True(!set.Pairs[0].Irreversible())
+			is.True(set.Pairs[1].Irreversible())
+			is.True(set.Pairs[2].Down == nil)
+			is.True(set.Pairs[2].Irreversible())
+			is.Equal(set.Find(2).Up.Name, "002_users.up.sql")
+			is.True(set.Find(4) == nil)
+			is.Equal(len(set.Ups()), 3)
+			is.Equal(len(set.Downs()), 2)
+			is.Equal(len(set.Repeatables), 1)
+			is.Equal(set.Repeatables[0].Name, "R_views.sql")
+
+			// conflicting versions
+			fsys["003_tags.up.sql"] = &fstest.MapFile{Data: []byte(``)}
+			_, err = migrate.Load(fsys)
+			is.True(err != nil)
+			is.Equal(err.Error(), "003_posts.up.sql and 003_tags.up.sql have the same version, run migrate renumber to fix")
+			delete(fsys, "003_tags.up.sql")
+
+			// mismatched names
+			fsys["003_tags.down.sql"] = &fstest.MapFile{Data: []byte(``)}
+			_, err = migrate.Load(fsys)
+			is.True(err != nil)
+			is.Equal(err.Error(), "003_posts.up.sql and 003_tags.down.sql have different names")
+			delete(fsys, "003_tags.down.sql")
+
+			// down without an up
+			fsys["004_tags.down.sql"] = &fstest.MapFile{Data: []byte(``)}
+			_, err = migrate.Load(fsys)
+			is.True(err != nil)
+			is.Equal(err.Error(), "004_tags.down.sql has no up migration")
+		},
+	},
+	{
+		name: "check reversible",
+		fn: func(t testing.TB, url string) {
+			is := is.New(t)
+			fsys := fstest.MapFS{
+				"001_teams.up.sql":    {Data: []byte(`create table teams (id integer primary key not null);`)},
+				"001_teams.down.sql":  {Data: []byte(`drop table teams;`)},
+				"002_users.up.sql":    {Data: []byte(`create table users (id integer primary key not null); create index users_id on users (id);`)},
+				"002_users.down.sql":  {Data: []byte(`drop index users_id;`)},
+				"003_posts.up.sql":    {Data: []byte(`create table posts (id integer primary key not null);`)},
+				"003_posts.down.sql":  {Data: []byte(`drop table missing;`)},
+				"004_tags.up.sql":     {Data: []byte(`create table tags (id integer primary key not null);`)},
+				"005_legacy.up.sql":   {Data: []byte("-- migrate:irreversible\ncreate table legacy (id integer primary key not null);")},
+				"005_legacy.down.sql": {Data: []byte(``)},
+			}
+			scratch, closeScratch, err := db.Scratch(url)
+			is.NoErr(err)
+			defer func() { is.NoErr(closeScratch()) }()
+			unreversed, err := migrate.CheckReversible(nil, scratch, fsys, tableName)
+			is.NoErr(err)
+			is.Equal(len(unreversed), 3)
+			is.Equal(unreversed[0].Migration.Name, "002_users.up.sql")
+			is.True(strings.Contains(unreversed[0].Diff, `DROP TABLE "users"`))
+			is.Equal(unreversed[1].Migration.Name, "003_posts.up.sql")
+			is.True(notExists(unreversed[1].Err, "missing"))
+			is.Equal(unreversed[2].Migration.Name, "004_tags.up.sql")
+			is.True(strings.Contains(unreversed[2].Err.Error(), "no down migration"))
+			// every migration ends up applied
+			var count int
+			is.NoErr(scratch.QueryRow(`select count(*) from legacy`).Scan(&count))
+			is.NoErr(scratch.QueryRow(`select count(*) from users`).Scan(&count))
+		},
+	},
 	{
 		name: "new",
 		fn: func(t testing.TB, url string) {
@@ -588,17 +1976,18 @@ var tests = []struct {
 			is.NoErr(os.RemoveAll("migrate"))
 			is.NoErr(os.MkdirAll("migrate", 0755))
 
-			err := migrate.New(nil, virt.OS("migrate"), "setup")
+			paths, err := migrate.New(nil, virt.OS("migrate"), "setup")
 			is.NoErr(err)
+			is.Equal(paths, []string{"001_setup.up.sql", "001_setup.down.sql"})
 			exists(t, "migrate/001_setup.up.sql")
 			exists(t, "migrate/001_setup.down.sql")
 
-			err = migrate.New(nil, virt.OS("migrate"), "create teams")
+			_, err = migrate.New(nil, virt.OS("migrate"), "create teams")
 			is.NoErr(err)
 			exists(t, "migrate/002_create_teams.up.sql")
 			exists(t, "migrate/002_create_teams.down.sql")
 
-			err = migrate.New(nil, virt.OS("migrate"), "new-users")
+			_, err = migrate.New(nil, virt.OS("migrate"), "new-users")
 			is.NoErr(err)
 			exists(t, "migrate/003_new_users.up.sql")
 			exists(t, "migrate/003_new_users.down.sql")
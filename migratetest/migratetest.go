@@ -0,0 +1,197 @@
+// Package migratetest provides isolated, migrated databases for Go tests.
+// Each database is migrated up and dropped when the test finishes, so
+// parallel tests don't interfere with each other.
+package migratetest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/matthewmueller/migrate"
+	"github.com/matthewmueller/migrate/internal/db"
+
+	// supported libraries
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TableName is the table that tracks the migrations
+const TableName = "migrate"
+
+// NewSQLite returns a SQLite database in a temporary directory that's been
+// migrated up with the migrations in fsys
+func NewSQLite(t testing.TB, fsys fs.FS, options ...migrate.Option) *sql.DB {
+	t.Helper()
+	conn, err := db.Dial("sqlite:" + filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("migratetest: unable to open sqlite database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := migrate.Up(nil, conn, fsys, TableName, options...); err != nil {
+		t.Fatalf("migratetest: unable to migrate sqlite database: %v", err)
+	}
+	return conn
+}
+
+// NewPostgres returns a uniquely named database on the Postgres server at
+// connString. The database is copied from a template database that's been
+// migrated up with the migrations in fsys. Templates are named after a hash
+// of the migrations, so they're only built once for each set of migrations.
+// Options like migrate.WithNamespace change the migrated schema but can't be
+// part of that hash, so NewPostgres doesn't take them.
+func NewPostgres(t testing.TB, connString string, fsys fs.FS) *sql.DB {
+	t.Helper()
+	admin, err := db.Dial(connString)
+	if err != nil {
+		t.Fatalf("migratetest: unable to connect to postgres: %v", err)
+	}
+	defer admin.Close()
+	template, err := ensureTemplate(admin, connString, fsys)
+	if err != nil {
+		t.Fatalf("migratetest: unable to create template database: %v", err)
+	}
+	suffix, err := randomHex(4)
+	if err != nil {
+		t.Fatalf("migratetest: unable to name database: %v", err)
+	}
+	name := template + "_" + suffix
+	if _, err := admin.Exec(`CREATE DATABASE ` + quote(name) + ` TEMPLATE ` + quote(template)); err != nil {
+		t.Fatalf("migratetest: unable to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := dropDatabase(connString, name); err != nil {
+			t.Errorf("migratetest: unable to drop database %s: %v", name, err)
+		}
+	})
+	conn, err := db.Dial(withDatabase(connString, name))
+	if err != nil {
+		t.Fatalf("migratetest: unable to connect to %s: %v", name, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// templates guards against building the same template twice in one process
+var templates sync.Mutex
+
+// ensureTemplate builds the template database for fsys if it doesn't exist.
+// The template is migrated under a temporary name and then renamed, so a
+// half-migrated template is never used.
+func ensureTemplate(admin *sql.DB, connString string, fsys fs.FS) (string, error) {
+	templates.Lock()
+	defer templates.Unlock()
+	hash, err := fingerprint(fsys)
+	if err != nil {
+		return "", err
+	}
+	name := "migratetest_" + hash
+	if exists, err := databaseExists(admin, name); err != nil {
+		return "", err
+	} else if exists {
+		return name, nil
+	}
+	suffix, err := randomHex(4)
+	if err != nil {
+		return "", err
+	}
+	building := name + "_build_" + suffix
+	if _, err := admin.Exec(`CREATE DATABASE ` + quote(building)); err != nil {
+		return "", err
+	}
+	if err := migrateTemplate(withDatabase(connString, building), fsys); err != nil {
+		return "", errors.Join(err, dropDatabase(connString, building))
+	}
+	if _, err := admin.Exec(`ALTER DATABASE ` + quote(building) + ` RENAME TO ` + quote(name)); err != nil {
+		// another process may have built the template first
+		exists, xerr := databaseExists(admin, name)
+		if xerr != nil || !exists {
+			return "", errors.Join(err, xerr, dropDatabase(connString, building))
+		}
+		return name, dropDatabase(connString, building)
+	}
+	return name, nil
+}
+
+// databaseExists returns true if the database exists on the server
+func databaseExists(admin *sql.DB, name string) (exists bool, err error) {
+	err = admin.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)`, name).Scan(&exists)
+	return exists, err
+}
+
+func migrateTemplate(connString string, fsys fs.FS) error {
+	conn, err := db.Dial(connString)
+	if err != nil {
+		return err
+	}
+	if err := migrate.Up(nil, conn, fsys, TableName); err != nil {
+		return errors.Join(err, conn.Close())
+	}
+	return conn.Close()
+}
+
+// dropDatabase drops the database, disconnecting anyone still connected
+func dropDatabase(connString, name string) error {
+	admin, err := db.Dial(connString)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	if _, err := admin.Exec(`SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()`, name); err != nil {
+		return err
+	}
+	_, err = admin.Exec(`DROP DATABASE IF EXISTS ` + quote(name))
+	return err
+}
+
+// fingerprint hashes the paths and contents of the files in fsys
+func fingerprint(fsys fs.FS) (string, error) {
+	hash := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(path string, de fs.DirEntry, err error) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		hash.Write([]byte(path + "\x00"))
+		hash.Write(data)
+		hash.Write([]byte{0})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12], nil
+}
+
+// withDatabase changes the database in the connection string
+func withDatabase(connString, name string) string {
+	u, err := url.Parse(connString)
+	if err != nil {
+		return connString
+	}
+	u.Path = "/" + name
+	return u.String()
+}
+
+// randomHex returns n random bytes in hex
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func quote(name string) string {
+	return `"` + name + `"`
+}
@@ -0,0 +1,46 @@
+package migratetest_test
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/matryer/is"
+	"github.com/matthewmueller/migrate"
+	"github.com/matthewmueller/migrate/migratetest"
+)
+
+var fsys = fstest.MapFS{
+	"001_users.up.sql":   &fstest.MapFile{Data: []byte(`create table users (id integer primary key not null, email text not null);`)},
+	"001_users.down.sql": &fstest.MapFile{Data: []byte(`drop table users;`)},
+}
+
+func TestPostgres(t *testing.T) {
+	url := "postgres://localhost:5432/migrate-test?sslmode=disable"
+	testIsolated(t, func() *sql.DB {
+		return migratetest.NewPostgres(t, url, fsys)
+	})
+}
+
+func TestSQLite(t *testing.T) {
+	testIsolated(t, func() *sql.DB {
+		return migratetest.NewSQLite(t, fsys)
+	})
+}
+
+// testIsolated checks that each database is migrated and isolated from the
+// others
+func testIsolated(t *testing.T, open func() *sql.DB) {
+	is := is.New(t)
+	db1, db2 := open(), open()
+	_, err := db1.Exec(`insert into users (id, email) values (1, 'a@b.c')`)
+	is.NoErr(err)
+	var count int
+	is.NoErr(db1.QueryRow(`select count(*) from users`).Scan(&count))
+	is.Equal(count, 1)
+	is.NoErr(db2.QueryRow(`select count(*) from users`).Scan(&count))
+	is.Equal(count, 0)
+	remote, err := migrate.RemoteVersion(db2, fsys, migratetest.TableName)
+	is.NoErr(err)
+	is.Equal(remote, "001_users.up.sql")
+}
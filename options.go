@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	database "github.com/matthewmueller/migrate/internal/db"
+)
+
+// Option configures Up, Down, Redo and friends
+type Option func(*config)
+
+type config struct {
+	log        *slog.Logger
+	hooks      Hooks
+	namespace  string
+	searchPath []string
+	dialect    string
+
+	// migrations executed in the current run
+	executed []*executed
+}
+
+// executed is a migration that ran successfully
+type executed struct {
+	migration  *Migration
+	duration   time.Duration
+	statements int
+}
+
+func newConfig(log *slog.Logger, options []Option) *config {
+	c := &config{
+		log:     log,
+		hooks:   NopHooks{},
+		dialect: "postgres",
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithHooks calls hooks around each run and each migration
+func WithHooks(hooks Hooks) Option {
+	return func(c *config) {
+		c.hooks = hooks
+	}
+}
+
+// WithNamespace tracks versions separately from other namespaces in the same
+// table. This lets a library ship its own sequence of migrations into a
+// database alongside the application's.
+func WithNamespace(namespace string) Option {
+	return func(c *config) {
+		c.namespace = namespace
+	}
+}
+
+// WithSearchPath sets the Postgres search_path while each migration runs, so
+// unqualified names in migrations resolve to these schemas. The search path is
+// restored before the version is recorded.
+func WithSearchPath(schemas ...string) Option {
+	return func(c *config) {
+		c.searchPath = schemas
+	}
+}
+
+// WithDialect sets the SQL dialect ("postgres" or "sqlite") of the migrations
+// that New prefills. The default is postgres.
+func WithDialect(dialect string) Option {
+	return func(c *config) {
+		c.dialect = dialect
+	}
+}
+
+// validate the options against the database before migrating it
+func (c *config) validate(db *sql.DB) error {
+	if len(c.searchPath) > 0 && database.Dialect(db) == "sqlite" {
+		return errors.New("unable to set the search path, sqlite doesn't support schemas")
+	}
+	return nil
+}
+
+// setSearchPath sets the search path for the rest of the transaction
+func (c *config) setSearchPath(tx *sql.Tx) error {
+	if len(c.searchPath) == 0 {
+		return nil
+	}
+	schemas := make([]string, len(c.searchPath))
+	for i, schema := range c.searchPath {
+		if !reIdentifier.MatchString(schema) {
+			return fmt.Errorf("invalid schema %q in search path", schema)
+		}
+		schemas[i] = quoteIdentifier(schema)
+	}
+	_, err := tx.Exec("SET LOCAL search_path TO " + strings.Join(schemas, ", "))
+	return err
+}
+
+// resetSearchPath undoes setSearchPath
+func (c *config) resetSearchPath(tx *sql.Tx) error {
+	if len(c.searchPath) == 0 {
+		return nil
+	}
+	_, err := tx.Exec("SET LOCAL search_path TO DEFAULT")
+	return err
+}
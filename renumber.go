@@ -0,0 +1,179 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/matthewmueller/virt"
+)
+
+// Rename is a migration file that Renumber moved
+type Rename struct {
+	From string
+	To   string
+}
+
+// Renumber resolves conflicting and out-of-sequence migrations, typically
+// after merging branches that each added a migration. Migrations that share
+// a version with another migration, or that haven't been applied but are
+// below the database's version, are moved to the next free versions in
+// order. Pass a nil db to only consider the local files.
+func Renumber(log *slog.Logger, db *sql.DB, fsys virt.FS, tableName string, options ...Option) (renames []*Rename, err error) {
+	log = logger(log)
+	config := newConfig(log, options)
+	files, err := getFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+	stems, err := migrationStems(files)
+	if err != nil {
+		return nil, err
+	}
+
+	// find what's been applied
+	applied := map[uint]bool{}
+	var remote uint
+	if db != nil {
+		if err := ensureTableExists(db, tableName); err != nil {
+			return nil, err
+		}
+		versions, err := getAppliedVersions(db, tableName, config.namespace)
+		if err != nil {
+			return nil, err
+		}
+		for _, version := range versions {
+			applied[version] = true
+			remote = max(remote, version)
+		}
+	}
+
+	// keep at most one migration per version, preferring the applied one
+	keep := remote
+	var movers []*stem
+	for i := 0; i < len(stems); {
+		j := i
+		for j < len(stems) && stems[j].version == stems[i].version {
+			j++
+		}
+		group := stems[i:j]
+		i = j
+		version := group[0].version
+		switch {
+		case applied[version]:
+			kept, err := appliedStem(db, tableName, config.namespace, group)
+			if err != nil {
+				return nil, err
+			}
+			for _, stem := range group {
+				if stem != kept {
+					movers = append(movers, stem)
+				}
+			}
+		case version < remote:
+			movers = append(movers, group...)
+		default:
+			movers = append(movers, group[1:]...)
+		}
+		keep = max(keep, version)
+	}
+
+	// move the rest after the last migration we kept
+	for _, stem := range movers {
+		keep++
+		for _, file := range stem.files {
+			_, rest, _ := strings.Cut(file, "_")
+			to := pad(keep) + "_" + rest
+			if err := move(fsys, file, to); err != nil {
+				return renames, err
+			}
+			log.Info("renamed: " + file + " -> " + to)
+			renames = append(renames, &Rename{From: file, To: to})
+		}
+	}
+	return renames, nil
+}
+
+// stem is a migration's up and down files
+type stem struct {
+	version uint
+	name    string // e.g. 015_add_users
+	files   []string
+}
+
+// migrationStems groups the migration files by name, sorted by version and
+// then by name
+func migrationStems(files map[string]string) ([]*stem, error) {
+	byName := map[string]*stem{}
+	for path := range files {
+		if !reFile.MatchString(path) {
+			continue
+		}
+		version, err := getVersion(path)
+		if err != nil {
+			return nil, err
+		}
+		dir, err := getDirection(path)
+		if err != nil {
+			return nil, err
+		}
+		name, _, _ := strings.Cut(path, "."+string(dir)+".")
+		if byName[name] == nil {
+			byName[name] = &stem{version: version, name: name}
+		}
+		byName[name].files = append(byName[name].files, path)
+	}
+	stems := make([]*stem, 0, len(byName))
+	for _, stem := range byName {
+		sort.Strings(stem.files)
+		stems = append(stems, stem)
+	}
+	sort.Slice(stems, func(i, j int) bool {
+		if stems[i].version != stems[j].version {
+			return stems[i].version < stems[j].version
+		}
+		return stems[i].name < stems[j].name
+	})
+	return stems, nil
+}
+
+// appliedStem figures out which of the migrations sharing a version was
+// applied, using the history table when there's more than one
+func appliedStem(db *sql.DB, tableName, namespace string, group []*stem) (*stem, error) {
+	if len(group) == 1 {
+		return group[0], nil
+	}
+	var name string
+	err := db.QueryRow("SELECT name FROM "+quote(historyTable(tableName))+" WHERE namespace=$1 AND version=$2 AND action IN ('up', 'baseline') ORDER BY created_at DESC LIMIT 1", namespace, group[0].version).Scan(&name)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	for _, stem := range group {
+		if strings.HasPrefix(name, stem.name+".") {
+			return stem, nil
+		}
+	}
+	names := make([]string, len(group))
+	for i, stem := range group {
+		names[i] = stem.name
+	}
+	return nil, fmt.Errorf("unable to tell which of %s was applied as version %s", strings.Join(names, ", "), pad(group[0].version))
+}
+
+// move a file within fsys
+func move(fsys virt.FS, from, to string) error {
+	if _, err := fs.Stat(fsys, to); err == nil {
+		return fmt.Errorf("unable to rename %s to %s, it already exists", from, to)
+	}
+	data, err := fs.ReadFile(fsys, from)
+	if err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(to, data, 0644); err != nil {
+		return err
+	}
+	return fsys.RemoveAll(from)
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+)
+
+// reRepeatable matches repeatable migrations (e.g. R_users_view.sql)
+var reRepeatable = regexp.MustCompile(`^R_.+\.sql$`)
+
+// repeatableTable is the name of the table that tracks the checksums of the
+// repeatable migrations
+func repeatableTable(tableName string) string {
+	return tableName + "_repeatable"
+}
+
+// repeatableMigrations returns the repeatable migrations sorted by name
+func repeatableMigrations(files map[string]string) (migs []*Migration) {
+	for path, code := range files {
+		if !reRepeatable.MatchString(path) {
+			continue
+		}
+		migs = append(migs, &Migration{
+			Name: path,
+			Code: code,
+			Dir:  DirectionUp,
+		})
+	}
+	sort.Slice(migs, func(i, j int) bool {
+		return migs[i].Name < migs[j].Name
+	})
+	return migs
+}
+
+// changedRepeatables returns the repeatable migrations that are new or have
+// changed since they were last applied
+func changedRepeatables(db *sql.DB, tableName, namespace string, repeatables []*Migration) ([]*Migration, error) {
+	if len(repeatables) == 0 {
+		return nil, nil
+	}
+	if err := ensureChecksumTableExists(db, repeatableTable(tableName)); err != nil {
+		return nil, err
+	}
+	applied, err := getChecksums(db, repeatableTable(tableName), namespace)
+	if err != nil {
+		return nil, err
+	}
+	var changed []*Migration
+	for _, migration := range repeatables {
+		if applied[migration.Name] != migration.Checksum() {
+			changed = append(changed, migration)
+		}
+	}
+	return changed, nil
+}
+
+// clearRepeatables forgets the repeatable migrations applied in the namespace.
+// Down migrations may drop the views and functions that repeatable migrations
+// created, so they're re-applied on the next Up.
+func clearRepeatables(tx *sql.Tx, tableName, namespace string) error {
+	_, err := tx.Exec("DELETE FROM "+quote(repeatableTable(tableName))+" WHERE namespace=$1", namespace)
+	return err
+}
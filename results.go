@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrSkipped happens when a target isn't migrated because an earlier target
+// failed
+var ErrSkipped = errors.New("skipped after an earlier failure")
+
+// Result of migrating a single target
+type Result struct {
+	Target  string
+	Version uint
+	Err     error
+}
+
+// Failed returns the results that have an error
+func Failed(results []*Result) (failed []*Result) {
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// target is something to migrate
+type target struct {
+	name    string
+	migrate func() (version uint, err error)
+}
+
+// migrateAll migrates the targets, up to concurrency at a time. Unless
+// keepGoing is true, targets that haven't started yet are skipped after the
+// first failure. Results are returned in the same order as the targets.
+func migrateAll(targets []target, concurrency int, keepGoing bool) []*Result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]*Result, len(targets))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for i, target := range targets {
+		semaphore <- struct{}{}
+		if failed.Load() && !keepGoing {
+			<-semaphore
+			results[i] = &Result{Target: target.name, Err: ErrSkipped}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			version, err := target.migrate()
+			if err != nil {
+				failed.Store(true)
+			}
+			results[i] = &Result{Target: target.name, Version: version, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
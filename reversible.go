@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+
+	"github.com/matthewmueller/migrate/internal/schema"
+)
+
+// Unreversed is a migration whose down migration doesn't cleanly reverse its
+// up migration
+type Unreversed struct {
+	Migration *Migration
+	// Diff restores the schema from before the up migration. It's empty when
+	// the down migration fails.
+	Diff string
+	Err  error
+}
+
+// CheckReversible applies each migration to the scratch database, which
+// should be empty, and checks that its down migration brings the schema back
+// to how it was before. The up migration is then re-applied before moving on
+// to the next migration, so every unreversed migration is reported, not just
+// the first. Migrations that are marked irreversible are skipped.
+func CheckReversible(log *slog.Logger, scratch *sql.DB, fsys fs.FS, tableName string, options ...Option) (unreversed []*Unreversed, err error) {
+	log = logger(log)
+	config := newConfig(log, options)
+	if err := config.validate(scratch); err != nil {
+		return nil, err
+	}
+	set, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if len(set.Pairs) == 0 {
+		return nil, noMigrations(set.files)
+	}
+	exec := func(migration *Migration) error {
+		return transaction(scratch, func(tx *sql.Tx) error {
+			return config.exec(tx, migration)
+		})
+	}
+	inspect := func() (*schema.Schema, error) {
+		return schema.Inspect(scratch, trackingTables(tableName)...)
+	}
+	for _, pair := range set.Pairs {
+		up, down := pair.Up, pair.Down
+		before, err := inspect()
+		if err != nil {
+			return unreversed, err
+		}
+		if err := exec(up); err != nil {
+			return unreversed, err
+		}
+		switch {
+		case down == nil:
+			unreversed = append(unreversed, &Unreversed{
+				Migration: up,
+				Err:       fmt.Errorf("%s has no down migration", up.Name),
+			})
+			continue
+		case pair.Irreversible():
+			log.Info("skipped: " + up.Name + " is marked irreversible")
+			continue
+		}
+		if err := exec(down); err != nil {
+			// the failed down migration was rolled back
+			unreversed = append(unreversed, &Unreversed{Migration: up, Err: err})
+			continue
+		}
+		after, err := inspect()
+		if err != nil {
+			return unreversed, err
+		}
+		if after.String() != before.String() {
+			restore := schema.Diff(after, before)
+			unreversed = append(unreversed, &Unreversed{
+				Migration: up,
+				Diff:      restore,
+				Err:       fmt.Errorf("%s doesn't restore the schema", down.Name),
+			})
+			// clean up after the down migration so the up migration can run again
+			if err := transaction(scratch, func(tx *sql.Tx) error {
+				_, err := tx.Exec(restore)
+				return err
+			}); err != nil {
+				return unreversed, fmt.Errorf("unable to restore the schema after %s: %w", down.Name, err)
+			}
+		} else {
+			log.Info("reversible: " + up.Name)
+		}
+		if err := exec(up); err != nil {
+			return unreversed, fmt.Errorf("unable to re-apply %s after %s: %w", up.Name, down.Name, err)
+		}
+	}
+	return unreversed, nil
+}
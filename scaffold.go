@@ -0,0 +1,126 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// templatesDir holds project templates within the migrations directory
+const templatesDir = "templates"
+
+// scaffold is the data passed to a migration template. The names are derived
+// from the migration name.
+type scaffold struct {
+	Kind    string // create, add_column, drop, add_index or default
+	Name    string
+	Table   string
+	Column  string
+	Index   string
+	Dialect string
+}
+
+var (
+	reCreate   = regexp.MustCompile(`^create_(.+)$`)
+	reAddIndex = regexp.MustCompile(`^add_index_on_(.+)_([^_]+)$`)
+	reAddCol   = regexp.MustCompile(`^add_(.+)_to_(.+)$`)
+	reDrop     = regexp.MustCompile(`^drop_(.+)$`)
+)
+
+// newScaffold recognizes conventional migration names. The column of an
+// index is the last word of the name (e.g. add_index_on_user_roles_email).
+func newScaffold(name, dialect string) *scaffold {
+	s := &scaffold{Kind: "default", Name: name, Dialect: dialect}
+	switch {
+	case reCreate.MatchString(name):
+		s.Kind, s.Table = "create", reCreate.FindStringSubmatch(name)[1]
+	case reAddIndex.MatchString(name):
+		match := reAddIndex.FindStringSubmatch(name)
+		s.Kind, s.Table, s.Column = "add_index", match[1], match[2]
+		s.Index = s.Table + "_" + s.Column + "_idx"
+	case reAddCol.MatchString(name):
+		match := reAddCol.FindStringSubmatch(name)
+		s.Kind, s.Column, s.Table = "add_column", match[1], match[2]
+	case reDrop.MatchString(name):
+		s.Kind, s.Table = "drop", reDrop.FindStringSubmatch(name)[1]
+	}
+	return s
+}
+
+// builtinTemplates are used when the project doesn't have its own
+var builtinTemplates = map[string][2]string{
+	"create": {
+		`create table {{ .Table }} (
+	{{- if eq .Dialect "sqlite" }}
+	id integer primary key autoincrement,
+	created_at datetime not null default current_timestamp,
+	updated_at datetime not null default current_timestamp
+	{{- else }}
+	id bigint generated always as identity primary key,
+	created_at timestamptz not null default now(),
+	updated_at timestamptz not null default now()
+	{{- end }}
+);
+`,
+		`drop table if exists {{ .Table }};
+`,
+	},
+	"add_column": {
+		`alter table {{ .Table }} add column {{ .Column }} text;
+`,
+		`alter table {{ .Table }} drop column {{ .Column }};
+`,
+	},
+	"drop": {
+		`drop table if exists {{ .Table }};
+`,
+		`-- migrate:irreversible
+`,
+	},
+	"add_index": {
+		`create index if not exists {{ .Index }} on {{ .Table }} ({{ .Column }});
+`,
+		`drop index if exists {{ .Index }};
+`,
+	},
+	"default": {"", ""},
+}
+
+// render the up and down code for the scaffold, preferring the project's
+// templates/<kind>.up.sql and templates/<kind>.down.sql
+func (s *scaffold) render(fsys fs.FS) (up, down string, err error) {
+	builtin := builtinTemplates[s.Kind]
+	if up, err = s.execute(fsys, s.Kind+".up.sql", builtin[0]); err != nil {
+		return "", "", err
+	}
+	if down, err = s.execute(fsys, s.Kind+".down.sql", builtin[1]); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+func (s *scaffold) execute(fsys fs.FS, name, fallback string) (string, error) {
+	code := fallback
+	data, err := fs.ReadFile(fsys, path.Join(templatesDir, name))
+	if err == nil {
+		code = string(data)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if strings.TrimSpace(code) == "" {
+		return "", nil
+	}
+	tpl, err := template.New(name).Parse(code)
+	if err != nil {
+		return "", err
+	}
+	out := new(bytes.Buffer)
+	if err := tpl.Execute(out, s); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// ErrNoSeeds happens when there are no seeds
+var ErrNoSeeds = errors.New("no seeds")
+
+// reAlways matches the directive for seeds that run every time
+var reAlways = regexp.MustCompile(`(?m)^\s*--\s*migrate:always\b`)
+
+// Seed runs the .sql files in fsys in order by name. Seeds are tracked
+// separately from the schema versions, so each seed only runs once. Seeds
+// marked with "-- migrate:always" run every time.
+func Seed(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, options ...Option) error {
+	log = logger(log)
+	config := newConfig(log, options)
+	if err := config.validate(db); err != nil {
+		return err
+	}
+	files, err := getFiles(fsys)
+	if err != nil {
+		if errors.Is(err, ErrNoMigrations) {
+			return ErrNoSeeds
+		}
+		return err
+	}
+	var seeds []*Migration
+	for name, code := range files {
+		if path.Ext(name) != ".sql" {
+			continue
+		}
+		seeds = append(seeds, &Migration{
+			Name: name,
+			Code: code,
+			Dir:  DirectionUp,
+		})
+	}
+	if len(seeds) == 0 {
+		return ErrNoSeeds
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		return seeds[i].Name < seeds[j].Name
+	})
+	if err := ensureChecksumTableExists(db, seedsTable(tableName)); err != nil {
+		return err
+	}
+	applied, err := getChecksums(db, seedsTable(tableName), config.namespace)
+	if err != nil {
+		return err
+	}
+
+	// find the seeds that need to run
+	var pending []*Migration
+	for _, seed := range seeds {
+		sum, ok := applied[seed.Name]
+		switch {
+		case !ok || reAlways.MatchString(seed.Code):
+			pending = append(pending, seed)
+		case sum != seed.Checksum():
+			log.Warn("seed changed after it was applied, skipping", "name", seed.Name)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return config.run(pending, func() error {
+		return transaction(db, func(tx *sql.Tx) error {
+			for _, seed := range pending {
+				if err := config.exec(tx, seed); err != nil {
+					return err
+				}
+				if err := upsertChecksum(tx, seedsTable(tableName), config.namespace, seed); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// seedsTable is the name of the table that tracks seeds
+func seedsTable(tableName string) string {
+	return tableName + "_seeds"
+}
@@ -0,0 +1,105 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+
+	"github.com/matthewmueller/virt"
+)
+
+// Source is a named filesystem of migrations
+type Source struct {
+	Name string
+	FS   fs.FS
+}
+
+// Sources merges multiple named sources into a single ordered set of
+// migrations. Versions must be unique across sources.
+func Sources(sources ...Source) fs.FS {
+	fileSystems := make([]fs.FS, len(sources))
+	for i, source := range sources {
+		fileSystems[i] = source.FS
+	}
+	return &sourcesFS{virt.Merge(fileSystems...), sources}
+}
+
+type sourcesFS struct {
+	fs.FS
+	sources []Source
+}
+
+// files reads the files from each source, returning an error when two sources
+// define the same version
+func (s *sourcesFS) files() (map[string]string, error) {
+	files := make(map[string]string)
+	owners := map[string]string{}
+	versions := map[string]string{}
+	for _, source := range s.sources {
+		sourceFiles, err := getFiles(source.FS)
+		if err != nil && err != ErrNoMigrations {
+			return nil, fmt.Errorf("unable to read source %q: %w", source.Name, err)
+		} else if !hasMigrations(sourceFiles) {
+			// a missing or empty source is likely a misconfigured directory
+			return nil, fmt.Errorf("%w in source %q", ErrNoMigrations, source.Name)
+		}
+		paths := make([]string, 0, len(sourceFiles))
+		for path := range sourceFiles {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			if owner, ok := owners[path]; ok {
+				return nil, fmt.Errorf("%s is defined in both source %q and source %q", path, owner, source.Name)
+			}
+			if reFile.MatchString(path) {
+				version, err := getVersion(path)
+				if err != nil {
+					return nil, err
+				}
+				dir, err := getDirection(path)
+				if err != nil {
+					return nil, err
+				}
+				key := fmt.Sprintf("%d.%s", version, dir)
+				if other, ok := versions[key]; ok && owners[other] != source.Name {
+					return nil, fmt.Errorf("version %s is defined in both source %q (%s) and source %q (%s)", pad(version), owners[other], other, source.Name, path)
+				}
+				versions[key] = path
+			}
+			owners[path] = source.Name
+			files[path] = sourceFiles[path]
+		}
+	}
+	if len(files) == 0 {
+		return nil, ErrNoMigrations
+	}
+	return files, nil
+}
+
+// hasMigrations returns true if any of the files are migrations
+func hasMigrations(files map[string]string) bool {
+	for path := range files {
+		if isMigrationFile(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceOf returns the name of the source that contains path
+func (s *sourcesFS) sourceOf(path string) string {
+	for _, source := range s.sources {
+		if _, err := fs.Stat(source.FS, path); err == nil {
+			return source.Name
+		}
+	}
+	return ""
+}
+
+// tag the migrations with the name of their source
+func (s *sourcesFS) tag(migrations []*Migration) {
+	for _, migration := range migrations {
+		migration.Source = s.sourceOf(migration.Name)
+	}
+}
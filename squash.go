@@ -0,0 +1,133 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/matthewmueller/migrate/internal/schema"
+	"github.com/matthewmueller/virt"
+)
+
+// reSquashed matches the directive at the top of a squashed migration
+var reSquashed = regexp.MustCompile(`(?m)^\s*--\s*migrate:squashed\b`)
+
+// archiveDir is where squashed migrations are moved to
+const archiveDir = "archive"
+
+// Squash replaces migrations 001 through `through` with a single baseline
+// migration. The migrations are applied to the scratch database, which should
+// be empty, and the resulting schema becomes the baseline. The originals are
+// moved into the archive/ directory.
+//
+// The baseline keeps version `through`, so databases that are already past it
+// won't run it again.
+func Squash(log *slog.Logger, scratch *sql.DB, fsys virt.FS, tableName string, through uint) error {
+	log = logger(log)
+	// files are moved within the root, so don't look for nested migrations
+	files, err := getFiles(fsys)
+	if err != nil {
+		return err
+	}
+	ups, err := toMigrations(files, DirectionUp)
+	if err != nil {
+		return err
+	}
+	if findMigration(ups, through) == nil {
+		return fmt.Errorf("unable to squash through %s, no migration has that version", pad(through))
+	}
+	var squashed []*Migration
+	for _, migration := range ups {
+		if migration.Version <= through {
+			squashed = append(squashed, migration)
+		}
+	}
+	if len(squashed) < 2 {
+		return fmt.Errorf("nothing to squash through %s", pad(through))
+	}
+
+	// find the originals to archive, without overwriting earlier archives
+	var originals []string
+	for name := range files {
+		if !reFile.MatchString(name) {
+			continue
+		}
+		version, err := getVersion(name)
+		if err != nil {
+			return err
+		} else if version > through {
+			continue
+		}
+		if _, err := fs.Stat(fsys, path.Join(archiveDir, name)); err == nil {
+			return fmt.Errorf("unable to archive %s, %s already exists", name, path.Join(archiveDir, name))
+		}
+		originals = append(originals, name)
+	}
+	sort.Strings(originals)
+
+	// build the schema on the scratch database
+	if err := UpBy(nil, scratch, fsys, tableName, len(squashed)); err != nil {
+		return err
+	}
+	snapshot, err := schema.Inspect(scratch, trackingTables(tableName)...)
+	if err != nil {
+		return err
+	}
+	upCode := fmt.Sprintf("-- migrate:squashed\n-- Squashed %s through %s.\n\n%s\n",
+		squashed[0].Name, squashed[len(squashed)-1].Name, snapshot.String())
+	downCode := schema.Diff(snapshot, &schema.Schema{Dialect: snapshot.Dialect}) + "\n"
+
+	// archive the originals
+	if err := fsys.MkdirAll(archiveDir, 0755); err != nil {
+		return err
+	}
+	for _, name := range originals {
+		if err := move(fsys, name, path.Join(archiveDir, name)); err != nil {
+			return err
+		}
+		log.Info("archived: " + name)
+	}
+
+	// write the baseline
+	filename := pad(through) + "_baseline"
+	if err := fsys.WriteFile(filename+".up.sql", []byte(upCode), 0644); err != nil {
+		return err
+	}
+	log.Info("wrote: " + filename + ".up.sql")
+	if err := fsys.WriteFile(filename+".down.sql", []byte(downCode), 0644); err != nil {
+		return err
+	}
+	log.Info("wrote: " + filename + ".down.sql")
+	return nil
+}
+
+// reconcileSquash marks databases that ran the migrations before they were
+// squashed by removing the versions that the baseline replaced. Databases
+// that stopped partway through the squashed migrations can't be upgraded.
+func reconcileSquash(db *sql.DB, tableName, namespace string, ups []*Migration) error {
+	var baseline *Migration
+	for _, migration := range ups {
+		if reSquashed.MatchString(migration.Code) {
+			baseline = migration
+		}
+	}
+	if baseline == nil {
+		return nil
+	}
+	remote, err := getRemoteVersion(db, tableName, namespace)
+	if err != nil {
+		return err
+	} else if remote == 0 {
+		return nil
+	} else if remote < baseline.Version {
+		return fmt.Errorf("database is at version %s, which was squashed into %s. Migrate it with the archived migrations first", pad(remote), baseline.Name)
+	}
+	if _, err := db.Exec("DELETE FROM "+quote(tableName)+" WHERE namespace=$1 AND version < $2 AND dirty=$3", namespace, baseline.Version, false); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"strings"
+	"unicode"
+)
+
+// countStatements counts the SQL statements in code, ignoring semicolons
+// within strings, quoted identifiers, comments and dollar-quoted bodies
+func countStatements(code string) (count int) {
+	pending := false // a statement has started but not ended
+	for i := 0; i < len(code); i++ {
+		switch ch := code[i]; {
+		case ch == ';':
+			if pending {
+				count++
+			}
+			pending = false
+		case ch == '-' && strings.HasPrefix(code[i:], "--"):
+			i = skipUntil(code, i+2, "\n")
+		case ch == '/' && strings.HasPrefix(code[i:], "/*"):
+			i = skipUntil(code, i+2, "*/")
+		case ch == '\'' || ch == '"' || ch == '`':
+			i = skipUntil(code, i+1, string(ch))
+			pending = true
+		case ch == '$':
+			if tag, ok := dollarTag(code[i:]); ok {
+				i = skipUntil(code, i+len(tag), tag)
+			}
+			pending = true
+		case !unicode.IsSpace(rune(ch)):
+			pending = true
+		}
+	}
+	if pending {
+		count++
+	}
+	return count
+}
+
+// skipUntil returns the index of the last byte of end, starting from i
+func skipUntil(code string, i int, end string) int {
+	n := strings.Index(code[i:], end)
+	if n < 0 {
+		return len(code)
+	}
+	return i + n + len(end) - 1
+}
+
+// dollarTag returns the $tag$ at the start of code, if any
+func dollarTag(code string) (string, bool) {
+	for i := 1; i < len(code); i++ {
+		switch ch := code[i]; {
+		case ch == '$':
+			return code[:i+1], true
+		case ch == '_' || unicode.IsLetter(rune(ch)) || (i > 1 && unicode.IsDigit(rune(ch))):
+			continue
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
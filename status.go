@@ -0,0 +1,40 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/fs"
+)
+
+// MigrationStatus describes whether a migration has been applied
+type MigrationStatus struct {
+	*Migration
+	Applied bool
+}
+
+// Status lists the local up migrations and whether each one has been applied
+func Status(db *sql.DB, fsys fs.FS, tableName string, options ...Option) (statuses []*MigrationStatus, err error) {
+	config := newConfig(nil, options)
+	if err := ensureTableExists(db, tableName); err != nil {
+		return nil, err
+	}
+	set, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	migrations := set.Ups()
+	versions, err := getAppliedVersions(db, tableName, config.namespace)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+	for _, migration := range migrations {
+		statuses = append(statuses, &MigrationStatus{
+			Migration: migration,
+			Applied:   applied[migration.Version],
+		})
+	}
+	return statuses, nil
+}
@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"database/sql"
+	"io/fs"
+	"log/slog"
+)
+
+// Schemas returns the Postgres schemas matching a LIKE pattern (e.g.
+// tenant_%), sorted by name
+func Schemas(db *sql.DB, pattern string) ([]string, error) {
+	return QuerySchemas(db, "SELECT schema_name FROM information_schema.schemata WHERE schema_name LIKE $1 ORDER BY schema_name", pattern)
+}
+
+// QuerySchemas returns the first column of each row returned by query
+func QuerySchemas(db *sql.DB, query string, args ...any) (schemas []string, err error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+// UpTenants migrates each Postgres schema up with the same migrations. Each
+// schema tracks its own versions in a migrate table within the schema and is
+// put on the search path while its migrations run. Up to concurrency schemas
+// are migrated at once. A failing schema doesn't stop the others, so check
+// the results for errors.
+func UpTenants(log *slog.Logger, db *sql.DB, fsys fs.FS, tableName string, schemas []string, concurrency int, options ...Option) []*Result {
+	log = logger(log)
+	_, table := splitTable(tableName)
+	targets := make([]target, len(schemas))
+	for i, schema := range schemas {
+		targets[i] = target{schema, func() (uint, error) {
+			tableName := schema + "." + table
+			options := append(options[:len(options):len(options)], WithSearchPath(schema))
+			if err := Up(log.With("tenant", schema), db, fsys, tableName, options...); err != nil {
+				return 0, err
+			}
+			return getRemoteVersion(db, tableName, newConfig(log, options).namespace)
+		}}
+	}
+	return migrateAll(targets, concurrency, true)
+}